@@ -0,0 +1,197 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/fuguiw/fg-lib/graceful"
+	"github.com/fuguiw/fg-lib/log"
+)
+
+// LogConfig is a ready-made config section for log.Options.
+// Embed it in an application config struct under a key such as "log".
+type LogConfig struct {
+	// Level is a slog level name: debug, info, warn or error.
+	Level    string `yaml:"level" json:"level" default:"info" env:"LOG_LEVEL"`
+	Format   string `yaml:"format" json:"format" default:"json" env:"LOG_FORMAT"`
+	Output   string `yaml:"output" json:"output" default:"stdout" env:"LOG_OUTPUT"`
+	FilePath string `yaml:"file_path" json:"file_path" env:"LOG_FILE_PATH"`
+
+	MaxSize    int  `yaml:"max_size" json:"max_size" default:"10"`
+	MaxBackups int  `yaml:"max_backups" json:"max_backups" default:"4"`
+	MaxAge     int  `yaml:"max_age" json:"max_age" default:"28"`
+	Compress   bool `yaml:"compress" json:"compress" default:"true"`
+
+	EnableCaller bool `yaml:"enable_caller" json:"enable_caller" default:"true"`
+	EnableStack  bool `yaml:"enable_stack" json:"enable_stack" default:"true"`
+
+	// Modules maps module names of log.Named loggers to their level names.
+	Modules map[string]string `yaml:"modules" json:"modules"`
+}
+
+// SlogLevel parses Level.
+func (c LogConfig) SlogLevel() (slog.Level, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(c.Level)); err != nil {
+		return 0, fmt.Errorf("invalid log level %q: %w", c.Level, err)
+	}
+	return level, nil
+}
+
+// ModuleLevels parses Modules.
+func (c LogConfig) ModuleLevels() (map[string]slog.Level, error) {
+	levels := make(map[string]slog.Level, len(c.Modules))
+	for module, name := range c.Modules {
+		var level slog.Level
+		if err := level.UnmarshalText([]byte(name)); err != nil {
+			return nil, fmt.Errorf("invalid log level %q for module %s: %w", name, module, err)
+		}
+		levels[module] = level
+	}
+	return levels, nil
+}
+
+// Options converts the section to log.Options for log.Init.
+func (c LogConfig) Options() (*log.Options, error) {
+	level, err := c.SlogLevel()
+	if err != nil {
+		return nil, err
+	}
+
+	return &log.Options{
+		Level:        level,
+		Format:       log.Format(c.Format),
+		Output:       log.Output(c.Output),
+		FilePath:     c.FilePath,
+		MaxSize:      c.MaxSize,
+		MaxBackups:   c.MaxBackups,
+		MaxAge:       c.MaxAge,
+		Compress:     c.Compress,
+		EnableCaller: c.EnableCaller,
+		EnableStack:  c.EnableStack,
+	}, nil
+}
+
+// GracefulConfig is a ready-made config section for graceful settings.
+type GracefulConfig struct {
+	// Timeout bounds how long components may take to stop.
+	Timeout time.Duration `yaml:"timeout" json:"timeout" default:"15s" env:"SHUTDOWN_TIMEOUT"`
+}
+
+// Options converts the section to graceful options for graceful.New.
+func (c GracefulConfig) Options() []graceful.Option {
+	return []graceful.Option{graceful.WithTimeout(c.Timeout)}
+}
+
+// BindLogLevel applies the log level and module levels from the current version via
+// log.SetLevel and log.SetModuleLevel, and keeps them in sync on every refresh. Modules
+// removed from the config go back to the global level. get returns the LogConfig
+// section of the config struct, e.g.
+//
+//	func(cfg interface{}) *config.LogConfig { return &cfg.(*AppConfig).Log }
+//
+// If get is nil, the first LogConfig field found in the config struct is used.
+// Invalid levels in later versions are reported with slog.Warn and leave the levels unchanged;
+// if the current version cannot be applied, the error is returned and nothing is bound.
+func BindLogLevel(l *Loader, get func(cfg interface{}) *LogConfig) error {
+	if get == nil {
+		get = findLogConfig
+	}
+	b := &logLevelBinding{get: get, modules: make(map[string]bool)}
+
+	// Watch before reading the current version, so none is missed in between. The
+	// lock keeps a callback from being overwritten by the older current version.
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	l.Watch(func(cfg interface{}) {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		if b.failed {
+			return
+		}
+		if err := b.apply(cfg); err != nil {
+			slog.Warn("config: log level not applied", "error", err)
+		}
+	})
+
+	if cfg := l.Current(); cfg != nil {
+		if err := b.apply(cfg); err != nil {
+			b.failed = true
+			return err
+		}
+	}
+	return nil
+}
+
+// logLevelBinding tracks the module levels it set, so it can unset removed ones.
+type logLevelBinding struct {
+	mu      sync.Mutex
+	get     func(cfg interface{}) *LogConfig
+	modules map[string]bool
+
+	// failed is set if the current version could not be applied by BindLogLevel,
+	// which leaves the binding inactive.
+	failed bool
+}
+
+// apply sets the levels of cfg. The caller must hold b.mu.
+func (b *logLevelBinding) apply(cfg interface{}) error {
+	c := b.get(cfg)
+	if c == nil {
+		return errors.New("config has no LogConfig section")
+	}
+	level, err := c.SlogLevel()
+	if err != nil {
+		return err
+	}
+	modules, err := c.ModuleLevels()
+	if err != nil {
+		return err
+	}
+
+	log.SetLevel(level)
+	for module := range b.modules {
+		if _, ok := modules[module]; !ok {
+			log.UnsetModuleLevel(module)
+			delete(b.modules, module)
+		}
+	}
+	for module, level := range modules {
+		log.SetModuleLevel(module, level)
+		b.modules[module] = true
+	}
+	return nil
+}
+
+// findLogConfig returns the first LogConfig field in cfg, searching nested structs.
+func findLogConfig(cfg interface{}) *LogConfig {
+	return findLogConfigValue(reflect.ValueOf(cfg))
+}
+
+func findLogConfigValue(v reflect.Value) *LogConfig {
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return nil
+	}
+	if c, ok := v.Addr().Interface().(*LogConfig); ok {
+		return c
+	}
+	for i := 0; i < v.NumField(); i++ {
+		if !v.Type().Field(i).IsExported() {
+			continue
+		}
+		if c := findLogConfigValue(v.Field(i)); c != nil {
+			return c
+		}
+	}
+	return nil
+}
@@ -1,26 +1,75 @@
 package config
 
 import (
+	"bytes"
+	"encoding"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"io/fs"
 	"os"
-	"path/filepath"
 	"reflect"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"gopkg.in/yaml.v3"
 )
 
 // Loader handles configuration loading and refreshing.
+//
+// Load, refreshes and Rollback run one at a time, so each version is read from all
+// sources as a consistent snapshot and versions are applied in order. Update callbacks
+// get the versions in the same order, after the load that applied them has finished,
+// so they may call Load, Reload or Rollback themselves.
 type Loader struct {
+	loadMu       sync.Mutex // serializes Load, refresh and Rollback
 	mu           sync.RWMutex
 	configFile   string
+	configDir    string
+	keyFile      string
+	keyEnv       string
+	fsys         fs.FS
+	migrations   map[int]Migration
+	strict       bool
+	validate     bool
 	cfg          interface{} // Pointer to the config struct
+	base         interface{} // Copy of the contents of cfg at the first load, seeding every load
 	onUpdateFunc func(interface{})
+	watchers     []func(interface{})
 	stopChan     chan struct{}
+
+	// Versions waiting to be passed to the update callbacks.
+	notifyMu   sync.Mutex
+	pending    []pendingNotice
+	delivering bool
+
+	lastRefresh RefreshStatus
+
+	// Version most recently copied into cfg by Load, which Save compares cfg with.
+	loadedCfg    interface{}
+	loadedResult *loadResult
+
+	// WithReader source, read once on first load.
+	reader       io.Reader
+	readerFormat Format
+	readerOnce   sync.Once
+	readerBytes  []byte
+	readerErr    error
+
+	// Current version, published atomically for lock-free reads.
+	current atomic.Pointer[interface{}]
+
+	// History of applied versions.
+	history       []Version
+	historySize   int
+	lastVersionID int
+	loadedHash    string // hash of the config most recently read from the sources
+
+	warnedKeys map[string]bool // deprecated keys already logged
 }
 
 // Option allows configuring the Loader.
@@ -46,28 +95,114 @@ func NewLoader(cfg interface{}, opts ...Option) *Loader {
 }
 
 // Load loads the configuration from defaults, file, and environment variables.
+// Every load builds a new value, publishes it as the current version (see Current)
+// and then copies it into the struct passed to NewLoader. Goroutines reading config
+// concurrently with Load or refreshes should use Current rather than that struct.
 func (l *Loader) Load() error {
+	defer l.deliver()
+	l.loadMu.Lock()
+	defer l.loadMu.Unlock()
+
+	cfg, result, hash, err := l.build()
+	if err != nil {
+		return err
+	}
+
 	l.mu.Lock()
-	defer l.mu.Unlock()
+	reflect.ValueOf(l.cfg).Elem().Set(reflect.ValueOf(cloneConfig(cfg)).Elem())
+	l.loadedCfg, l.loadedResult = cfg, result
+	deprecated := l.publish(cfg, hash, result)
+	l.enqueue(cfg)
+	l.mu.Unlock()
 
-	// 1. Process Defaults
-	if err := processDefaults(l.cfg); err != nil {
-		return fmt.Errorf("failed to process defaults: %w", err)
+	warnDeprecated(deprecated)
+	return nil
+}
+
+// Reload re-reads all sources and applies the result the same way an auto refresh does.
+// Unlike Load, it does not modify the struct passed to NewLoader.
+func (l *Loader) Reload() error {
+	return l.refresh()
+}
+
+// build runs the load pipeline into a new value seeded with the contents the struct
+// passed to NewLoader had at the first load, and returns it with its load result and hash.
+// The caller must hold l.loadMu.
+func (l *Loader) build() (interface{}, *loadResult, string, error) {
+	if t := reflect.TypeOf(l.cfg); t == nil || t.Kind() != reflect.Ptr || t.Elem().Kind() != reflect.Struct {
+		return nil, nil, "", fmt.Errorf("config must be a pointer to a struct, got %T", l.cfg)
+	}
+	if l.base == nil {
+		l.mu.RLock()
+		l.base = cloneConfig(l.cfg)
+		l.mu.RUnlock()
 	}
 
-	// 2. Load File (if specified and exists)
-	if l.configFile != "" {
-		if err := loadFile(l.configFile, l.cfg); err != nil {
-			return fmt.Errorf("failed to load config file: %w", err)
-		}
+	cfg := cloneConfig(l.base)
+	result, err := l.loadInto(cfg)
+	if err != nil {
+		return nil, nil, "", err
+	}
+
+	return cfg, result, hashConfig(cfg), nil
+}
+
+// publish makes cfg the current version and returns the deprecated keys it uses
+// that have not been warned about yet. The caller must hold l.mu.
+func (l *Loader) publish(cfg interface{}, hash string, result *loadResult) []DeprecatedKey {
+	l.loadedHash = hash
+	l.record(cfg, hash, result)
+	l.current.Store(&cfg)
+	return l.unwarnedKeys(result.deprecated)
+}
+
+// loadResult describes where the values of a loaded version came from.
+type loadResult struct {
+	// sources maps key paths to the source that set them.
+	sources map[string]string
+
+	// deprecated lists the deprecated keys found in the sources.
+	deprecated []DeprecatedKey
+
+	// encrypted holds the paths of values decrypted from ENC[...], which are masked
+	// wherever a version is shown.
+	encrypted map[string]bool
+}
+
+// loadInto runs the load pipeline into ptr. Problems in defaults, files, env vars and
+// encrypted values are collected and returned together; validation only runs without them.
+func (l *Loader) loadInto(ptr interface{}) (*loadResult, error) {
+	result := &loadResult{sources: make(map[string]string), encrypted: make(map[string]bool)}
+
+	var errs []error
+	collect := func(err error) {
+		errs = append(errs, flattenErrors(err)...)
 	}
 
+	// 1. Process Defaults
+	collect(processDefaults(ptr, result.sources))
+
+	// 2. Load File and Directory (if specified and exists)
+	collect(l.loadFiles(ptr, result))
+
 	// 3. Process Environment Variables
-	if err := processEnv(l.cfg); err != nil {
-		return fmt.Errorf("failed to process env vars: %w", err)
+	collect(processEnv(ptr, result.sources))
+
+	// 4. Decrypt ENC[...] values
+	collect(l.processEncrypted(ptr, result))
+
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("failed to load config: %w", errors.Join(errs...))
 	}
 
-	return nil
+	// 5. Validate
+	if l.validate {
+		if err := validate(reflect.ValueOf(ptr), ""); err != nil {
+			return nil, fmt.Errorf("invalid config: %w", err)
+		}
+	}
+
+	return result, nil
 }
 
 func (l *Loader) MustLoad() error {
@@ -77,8 +212,27 @@ func (l *Loader) MustLoad() error {
 	return nil
 }
 
+// RefreshStatus describes the outcome of the most recent refresh or Reload.
+type RefreshStatus struct {
+	// Time is when the refresh finished; zero if no refresh has run yet.
+	Time time.Time
+
+	// Changed reports whether a new version was applied.
+	Changed bool
+
+	// Err is the error that aborted the refresh, if any.
+	Err error
+}
+
+// LastRefresh returns the status of the most recent refresh.
+func (l *Loader) LastRefresh() RefreshStatus {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.lastRefresh
+}
+
 // StartAutoRefresh starts a periodic refresh of the configuration.
-// It runs in a background goroutine.
+// It runs in a background goroutine and calls onUpdate whenever the loaded configuration changes.
 func (l *Loader) StartAutoRefresh(interval time.Duration, onUpdate func(interface{})) {
 	l.mu.Lock()
 	l.onUpdateFunc = onUpdate
@@ -104,60 +258,127 @@ func (l *Loader) StopAutoRefresh() {
 	close(l.stopChan)
 }
 
-func (l *Loader) refresh() {
-	// Create a new instance of the config struct to avoid modifying the current one in place
-	// while it might be in use, although for this simple implementation we might just reload
-	// into a new struct and then callback.
+func (l *Loader) refresh() (err error) {
+	defer l.deliver()
+	l.loadMu.Lock()
+	defer l.loadMu.Unlock()
+
+	changed := false
+	defer func() {
+		l.mu.Lock()
+		l.lastRefresh = RefreshStatus{Time: time.Now(), Changed: changed, Err: err}
+		l.mu.Unlock()
+	}()
 
-	// Reflection to create a new pointer to the same type as l.cfg
-	t := reflect.TypeOf(l.cfg)
-	if t.Kind() != reflect.Ptr {
-		return // Should not happen if Load() succeeded
+	cfg, result, hash, err := l.build()
+	if err != nil {
+		return err
 	}
 
-	newCfgVal := reflect.New(t.Elem())
-	newCfg := newCfgVal.Interface()
+	// Skip unchanged sources, so a rolled back version stays in effect
+	// until the file or environment changes again.
+	l.mu.Lock()
+	if hash == l.loadedHash {
+		l.mu.Unlock()
+		return nil
+	}
+	deprecated := l.publish(cfg, hash, result)
+	l.enqueue(cfg)
+	l.mu.Unlock()
+	changed = true
 
-	// 1. Defaults
-	if err := processDefaults(newCfg); err != nil {
-		// Log error? For now, just ignore failed refresh
-		return
+	warnDeprecated(deprecated)
+	return nil
+}
+
+// Watch registers fn to be called with every newly applied version, whether applied by
+// Load, a refresh or Rollback, after the StartAutoRefresh callback. Unlike StartAutoRefresh
+// it can be called any number of times, so independent components can follow config changes.
+func (l *Loader) Watch(fn func(interface{})) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.watchers = append(l.watchers, fn)
+}
+
+// Current returns the most recently applied version, or nil before the first Load.
+// It never blocks and is safe to call from any goroutine. The returned value is shared
+// by all readers and must not be modified.
+func (l *Loader) Current() interface{} {
+	cfg := l.current.Load()
+	if cfg == nil {
+		return nil
 	}
+	return *cfg
+}
 
-	// 2. File
-	if l.configFile != "" {
-		if err := loadFile(l.configFile, newCfg); err != nil {
-			return
-		}
+// listeners returns the update callbacks in call order. The caller must hold l.mu.
+func (l *Loader) listeners() []func(interface{}) {
+	var fns []func(interface{})
+	if l.onUpdateFunc != nil {
+		fns = append(fns, l.onUpdateFunc)
 	}
+	return append(fns, l.watchers...)
+}
+
+// pendingNotice is a version waiting to be passed to the listeners registered when it was applied.
+type pendingNotice struct {
+	listeners []func(interface{})
+	cfg       interface{}
+}
 
-	// 3. Env
-	if err := processEnv(newCfg); err != nil {
+// enqueue queues cfg for the update callbacks. The caller must hold l.mu.
+func (l *Loader) enqueue(cfg interface{}) {
+	listeners := l.listeners()
+	if len(listeners) == 0 {
 		return
 	}
+	l.notifyMu.Lock()
+	l.pending = append(l.pending, pendingNotice{listeners: listeners, cfg: cfg})
+	l.notifyMu.Unlock()
+}
 
-	// Notify
-	l.mu.RLock()
-	callback := l.onUpdateFunc
-	l.mu.RUnlock()
+// deliver passes queued versions to the update callbacks in order. It is called after
+// l.loadMu is released; if another call is already delivering, including one further up
+// the stack of a callback, it returns and leaves the queue to that call.
+func (l *Loader) deliver() {
+	l.notifyMu.Lock()
+	if l.delivering {
+		l.notifyMu.Unlock()
+		return
+	}
+	l.delivering = true
+	for len(l.pending) > 0 {
+		n := l.pending[0]
+		l.pending = l.pending[1:]
+		l.notifyMu.Unlock()
+		notify(n.listeners, n.cfg)
+		l.notifyMu.Lock()
+	}
+	l.delivering = false
+	l.notifyMu.Unlock()
+}
 
-	if callback != nil {
-		callback(newCfg)
+// notify calls each listener with its own copy of cfg, so the published version stays unmodified.
+func notify(fns []func(interface{}), cfg interface{}) {
+	for _, fn := range fns {
+		fn(cloneConfig(cfg))
 	}
 }
 
 // processDefaults sets default values defined in `default` tag.
-func processDefaults(ptr interface{}) error {
+func processDefaults(ptr interface{}, sources map[string]string) error {
 	v := reflect.ValueOf(ptr).Elem()
-	return setDefaults(v)
+	return setDefaults(v, "", sources)
 }
 
-func setDefaults(v reflect.Value) error {
+func setDefaults(v reflect.Value, prefix string, sources map[string]string) error {
 	t := v.Type()
+	var errs []error
 
 	for i := 0; i < v.NumField(); i++ {
 		fieldVal := v.Field(i)
 		fieldType := t.Field(i)
+		path := joinPath(prefix, keyName(fieldType))
 
 		if !fieldVal.CanSet() {
 			continue
@@ -165,18 +386,14 @@ func setDefaults(v reflect.Value) error {
 
 		// Handle recursion for nested structs
 		if fieldVal.Kind() == reflect.Struct {
-			if err := setDefaults(fieldVal); err != nil {
-				return err
-			}
+			errs = append(errs, setDefaults(fieldVal, path, sources))
 			continue
 		} else if fieldVal.Kind() == reflect.Ptr && fieldVal.Elem().Kind() == reflect.Struct {
 			// Initialize pointer if nil and it has defaults?
 			// For simplicity, skip nil pointers or initialize them?
 			// Let's skip nil pointers for now unless we want to allocate everything.
 			if !fieldVal.IsNil() {
-				if err := setDefaults(fieldVal.Elem()); err != nil {
-					return err
-				}
+				errs = append(errs, setDefaults(fieldVal.Elem(), path, sources))
 			}
 			continue
 		}
@@ -184,45 +401,55 @@ func setDefaults(v reflect.Value) error {
 		defaultVal := fieldType.Tag.Get("default")
 		if defaultVal != "" && isZero(fieldVal) {
 			if err := setValue(fieldVal, defaultVal); err != nil {
-				return fmt.Errorf("failed to set default for field %s: %w", fieldType.Name, err)
+				errs = append(errs, newFieldError(path, SourceDefault, defaultVal, fieldType, err))
+				continue
 			}
+			sources[path] = SourceDefault
 		}
 	}
-	return nil
+	return errors.Join(errs...)
 }
 
-// loadFile reads and parses the config file.
-func loadFile(path string, ptr interface{}) error {
-	data, err := os.ReadFile(path)
-	if err != nil {
-		// If file doesn't exist and we just want to use defaults/env, maybe ignore?
-		// Requirement implies "Support file config", usually if file is specified but missing, it's an error.
-		return err
-	}
-
-	ext := strings.ToLower(filepath.Ext(path))
-	switch ext {
-	case ".json":
-		return json.Unmarshal(data, ptr)
-	case ".yaml", ".yml":
-		return yaml.Unmarshal(data, ptr)
+// decode parses data in the given format into ptr.
+// In strict mode, keys that do not map to a field are errors.
+func decode(data []byte, format Format, ptr interface{}, strict bool) error {
+	switch format {
+	case FormatJSON:
+		if !strict {
+			return json.Unmarshal(data, ptr)
+		}
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		return dec.Decode(ptr)
+	case FormatYAML:
+		if !strict {
+			return yaml.Unmarshal(data, ptr)
+		}
+		dec := yaml.NewDecoder(bytes.NewReader(data))
+		dec.KnownFields(true)
+		if err := dec.Decode(ptr); err != nil && err != io.EOF {
+			return err
+		}
+		return nil
 	default:
-		return fmt.Errorf("unsupported file extension: %s", ext)
+		return fmt.Errorf("unsupported format: %s", format)
 	}
 }
 
 // processEnv sets values from environment variables defined in `env` tag.
-func processEnv(ptr interface{}) error {
+func processEnv(ptr interface{}, sources map[string]string) error {
 	v := reflect.ValueOf(ptr).Elem()
-	return setEnv(v)
+	return setEnv(v, "", sources)
 }
 
-func setEnv(v reflect.Value) error {
+func setEnv(v reflect.Value, prefix string, sources map[string]string) error {
 	t := v.Type()
+	var errs []error
 
 	for i := 0; i < v.NumField(); i++ {
 		fieldVal := v.Field(i)
 		fieldType := t.Field(i)
+		path := joinPath(prefix, keyName(fieldType))
 
 		if !fieldVal.CanSet() {
 			continue
@@ -230,14 +457,10 @@ func setEnv(v reflect.Value) error {
 
 		// Handle recursion
 		if fieldVal.Kind() == reflect.Struct {
-			if err := setEnv(fieldVal); err != nil {
-				return err
-			}
+			errs = append(errs, setEnv(fieldVal, path, sources))
 			continue
 		} else if fieldVal.Kind() == reflect.Ptr && !fieldVal.IsNil() && fieldVal.Elem().Kind() == reflect.Struct {
-			if err := setEnv(fieldVal.Elem()); err != nil {
-				return err
-			}
+			errs = append(errs, setEnv(fieldVal.Elem(), path, sources))
 			continue
 		}
 
@@ -246,16 +469,25 @@ func setEnv(v reflect.Value) error {
 			val := os.Getenv(envKey)
 			if val != "" {
 				if err := setValue(fieldVal, val); err != nil {
-					return fmt.Errorf("failed to set env %s for field %s: %w", envKey, fieldType.Name, err)
+					errs = append(errs, newFieldError(path, SourceEnvPrefix+envKey, val, fieldType, err))
+					continue
 				}
+				sources[path] = SourceEnvPrefix + envKey
 			}
 		}
 	}
-	return nil
+	return errors.Join(errs...)
 }
 
 // setValue converts string to the field's type and sets it.
 func setValue(v reflect.Value, s string) error {
+	// Types such as ByteSize, Percent and Duration parse themselves
+	if v.CanAddr() {
+		if u, ok := v.Addr().Interface().(encoding.TextUnmarshaler); ok {
+			return u.UnmarshalText([]byte(s))
+		}
+	}
+
 	switch v.Kind() {
 	case reflect.String:
 		v.SetString(s)
@@ -270,7 +502,7 @@ func setValue(v reflect.Value, s string) error {
 		if err != nil {
 			// Try parsing duration if it fails as int
 			if v.Type() == reflect.TypeOf(time.Duration(0)) {
-				d, err := time.ParseDuration(s)
+				d, err := ParseDuration(s)
 				if err != nil {
 					return err
 				}
@@ -309,6 +541,63 @@ func setValue(v reflect.Value, s string) error {
 	return nil
 }
 
+// keyName returns the name of a field in config files and key paths:
+// the yaml tag, then the json tag, then the lowercased field name.
+func keyName(f reflect.StructField) string {
+	for _, tag := range []string{"yaml", "json"} {
+		if name, _, _ := strings.Cut(f.Tag.Get(tag), ","); name != "" && name != "-" {
+			return name
+		}
+	}
+	return strings.ToLower(f.Name)
+}
+
+// documentKey returns the key of a field in a document of the given format, as its
+// decoder matches it: the json tag or field name for JSON, the yaml tag or lowercased
+// field name for YAML. It returns "" for fields the format skips.
+func documentKey(f reflect.StructField, format Format) string {
+	tag := "yaml"
+	if format == FormatJSON {
+		tag = "json"
+	}
+	name, _, _ := strings.Cut(f.Tag.Get(tag), ",")
+	switch {
+	case name == "-":
+		return ""
+	case name != "":
+		return name
+	case format == FormatJSON:
+		return f.Name
+	default:
+		return strings.ToLower(f.Name)
+	}
+}
+
+// isInline reports whether the fields of an embedded struct are decoded as if they
+// were fields of the outer struct in documents of the given format.
+func isInline(f reflect.StructField, format Format) bool {
+	if !f.Anonymous {
+		return false
+	}
+	if format == FormatJSON {
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		ft := f.Type
+		if ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+		return name == "" && ft.Kind() == reflect.Struct
+	}
+	return strings.Contains(f.Tag.Get("yaml"), ",inline")
+}
+
+// joinPath joins a dotted key path prefix and a key name.
+func joinPath(prefix, name string) string {
+	if prefix == "" {
+		return name
+	}
+	return prefix + "." + name
+}
+
 func isZero(v reflect.Value) bool {
 	return v.IsZero()
 }
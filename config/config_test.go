@@ -0,0 +1,704 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/fuguiw/fg-lib/log"
+	"github.com/stretchr/testify/assert"
+)
+
+type testDBConfig struct {
+	Host     string `yaml:"host" json:"host" default:"localhost" env:"TEST_DB_HOST"`
+	Port     int    `yaml:"port" json:"port" default:"3306"`
+	Password string `yaml:"password" json:"password" secret:"true"`
+}
+
+type testConfig struct {
+	Name     string       `yaml:"name" json:"name" default:"app"`
+	Debug    bool         `yaml:"debug" json:"debug"`
+	Tags     []string     `yaml:"tags" json:"tags"`
+	Database testDBConfig `yaml:"database" json:"database"`
+}
+
+func writeFile(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	assert.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+	assert.NoError(t, os.WriteFile(path, []byte(content), 0644))
+	return path
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	path := writeFile(t, dir, "config.yaml", "name: svc\ndatabase:\n  port: 5432\n")
+	t.Setenv("TEST_DB_HOST", "db.internal")
+
+	var cfg testConfig
+	err := NewLoader(&cfg, WithFile(path)).Load()
+	assert.NoError(t, err)
+	assert.Equal(t, "svc", cfg.Name)
+	assert.Equal(t, "db.internal", cfg.Database.Host)
+	assert.Equal(t, 5432, cfg.Database.Port)
+}
+
+func TestLoad_Include(t *testing.T) {
+	dir := t.TempDir()
+	path := writeFile(t, dir, "config.yaml", "name: base\ninclude:\n  - conf.d/*.yaml\n  - extra.json\n")
+	writeFile(t, dir, "conf.d/10-db.yaml", "database:\n  host: db1\n")
+	writeFile(t, dir, "conf.d/20-db.yaml", "database:\n  host: db2\n  port: 1234\n")
+	writeFile(t, dir, "extra.json", `{"debug": true}`)
+
+	var cfg testConfig
+	err := NewLoader(&cfg, WithFile(path)).Load()
+	assert.NoError(t, err)
+	assert.Equal(t, "base", cfg.Name)
+	assert.Equal(t, "db2", cfg.Database.Host)
+	assert.Equal(t, 1234, cfg.Database.Port)
+	assert.True(t, cfg.Debug)
+}
+
+func TestLoad_IncludeCycle(t *testing.T) {
+	dir := t.TempDir()
+	path := writeFile(t, dir, "a.yaml", "include: b.yaml\n")
+	writeFile(t, dir, "b.yaml", "include: a.yaml\n")
+
+	var cfg testConfig
+	err := NewLoader(&cfg, WithFile(path)).Load()
+	assert.ErrorContains(t, err, "include cycle detected")
+}
+
+func TestLoad_Dir(t *testing.T) {
+	dir := t.TempDir()
+	path := writeFile(t, dir, "config.yaml", "name: base\ndatabase:\n  port: 1\n")
+	confd := filepath.Join(dir, "conf.d")
+	writeFile(t, confd, "b.json", `{"database": {"port": 3}}`)
+	writeFile(t, confd, "a.yaml", "database:\n  port: 2\n  host: from-a\n")
+	writeFile(t, confd, "notes.txt", "ignored")
+
+	var cfg testConfig
+	err := NewLoader(&cfg, WithFile(path), WithDir(confd)).Load()
+	assert.NoError(t, err)
+	assert.Equal(t, "base", cfg.Name)
+	assert.Equal(t, "from-a", cfg.Database.Host)
+	assert.Equal(t, 3, cfg.Database.Port)
+}
+
+func TestLoad_DirReportsFailingFile(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "a.yaml", "name: ok\n")
+	bad := writeFile(t, dir, "b.yaml", "name: [unclosed\n")
+
+	var cfg testConfig
+	err := NewLoader(&cfg, WithDir(dir)).Load()
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), bad)
+}
+
+type testComponentsConfig struct {
+	Log      LogConfig      `yaml:"log"`
+	Graceful GracefulConfig `yaml:"graceful"`
+}
+
+func TestComponents(t *testing.T) {
+	dir := t.TempDir()
+	path := writeFile(t, dir, "config.yaml", "log:\n  level: warn\n  compress: false\ngraceful:\n  timeout: 3s\n")
+
+	var cfg testComponentsConfig
+	l := NewLoader(&cfg, WithFile(path))
+	assert.NoError(t, l.Load())
+
+	opts, err := cfg.Log.Options()
+	assert.NoError(t, err)
+	assert.Equal(t, slog.LevelWarn, opts.Level)
+	assert.Equal(t, log.FormatJSON, opts.Format)
+	assert.False(t, opts.Compress)
+	assert.True(t, opts.EnableCaller)
+	assert.Equal(t, 3*time.Second, cfg.Graceful.Timeout)
+	assert.Len(t, cfg.Graceful.Options(), 1)
+
+	defer log.SetLevel(log.GetLevel())
+	get := func(c interface{}) *LogConfig { return &c.(*testComponentsConfig).Log }
+	assert.NoError(t, BindLogLevel(l, get))
+	assert.Equal(t, slog.LevelWarn, log.GetLevel())
+
+	writeFile(t, dir, "config.yaml", "log:\n  level: debug\n")
+	assert.NoError(t, l.Reload())
+	assert.Equal(t, slog.LevelDebug, log.GetLevel())
+
+	// Invalid levels leave the current level in place.
+	writeFile(t, dir, "config.yaml", "log:\n  level: loud\n")
+	assert.NoError(t, l.Reload())
+	assert.Equal(t, slog.LevelDebug, log.GetLevel())
+}
+
+func TestLoad_FS(t *testing.T) {
+	fsys := fstest.MapFS{
+		"defaults/config.yaml":  {Data: []byte("name: embedded\ninclude: db/*.yaml\n")},
+		"defaults/db/main.yaml": {Data: []byte("database:\n  host: embedded-db\n")},
+		"conf.d/10.json":        {Data: []byte(`{"debug": true}`)},
+	}
+
+	var cfg testConfig
+	l := NewLoader(&cfg, WithFS(fsys), WithFile("defaults/config.yaml"), WithDir("conf.d"))
+	assert.NoError(t, l.Load())
+	assert.Equal(t, "embedded", cfg.Name)
+	assert.Equal(t, "embedded-db", cfg.Database.Host)
+	assert.True(t, cfg.Debug)
+	assert.Equal(t, SourceFilePrefix+"defaults/db/main.yaml", l.Provenance()["database.host"])
+}
+
+func TestLoad_Reader(t *testing.T) {
+	dir := t.TempDir()
+	path := writeFile(t, dir, "config.yaml", "database:\n  port: 5432\n")
+
+	var cfg testConfig
+	r := strings.NewReader(`{"name": "from-reader", "database": {"port": 1}}`)
+	l := NewLoader(&cfg, WithReader(r, FormatJSON), WithFile(path))
+	assert.NoError(t, l.Load())
+	assert.Equal(t, "from-reader", cfg.Name)
+	assert.Equal(t, 5432, cfg.Database.Port)
+	assert.Equal(t, SourceReader, l.Provenance()["name"])
+
+	// Refreshes reuse the content read on the first load.
+	writeFile(t, dir, "config.yaml", "database:\n  port: 6543\n")
+	assert.NoError(t, l.Reload())
+	current := l.Current().(*testConfig)
+	assert.Equal(t, "from-reader", current.Name)
+	assert.Equal(t, 6543, current.Database.Port)
+
+	// Includes in a reader would resolve against the working directory, so they are rejected.
+	cfg = testConfig{}
+	r = strings.NewReader("include: conf.d/*.yaml\nname: from-reader\n")
+	err := NewLoader(&cfg, WithReader(r, FormatYAML)).Load()
+	assert.ErrorIs(t, err, errReaderInclude)
+}
+
+type testRenamedConfig struct {
+	Name     string `yaml:"name" deprecated:"app_name"`
+	Database struct {
+		Host string `yaml:"host" deprecated:"db_host,database.hostname"`
+		Port int    `yaml:"port"`
+	} `yaml:"database"`
+}
+
+func TestLoad_DeprecatedKeys(t *testing.T) {
+	dir := t.TempDir()
+	path := writeFile(t, dir, "config.yaml", "app_name: legacy\nname: current\ndatabase:\n  hostname: old-db\n  port: 1\n")
+
+	var logs bytes.Buffer
+	defer slog.SetDefault(slog.Default())
+	slog.SetDefault(slog.New(slog.NewTextHandler(&logs, nil)))
+
+	var cfg testRenamedConfig
+	l := NewLoader(&cfg, WithFile(path))
+	assert.NoError(t, l.Load())
+
+	// The new key wins when both are present.
+	assert.Equal(t, "current", cfg.Name)
+	assert.Equal(t, "old-db", cfg.Database.Host)
+	assert.Equal(t, 1, cfg.Database.Port)
+	assert.Equal(t, SourceFilePrefix+path, l.Provenance()["database.host"])
+
+	source := SourceFilePrefix + path
+	assert.ElementsMatch(t, []DeprecatedKey{
+		{Key: "app_name", Replacement: "name", Source: source},
+		{Key: "database.hostname", Replacement: "database.host", Source: source},
+	}, l.DeprecatedKeys())
+
+	// Each key is warned about once.
+	assert.NoError(t, l.Load())
+	assert.Equal(t, 2, strings.Count(logs.String(), "deprecated key in use"))
+	assert.Equal(t, 1, strings.Count(logs.String(), "key=app_name replacement=name source="+source))
+
+	writeFile(t, dir, "config.yaml", "name: current\ndatabase:\n  host: new-db\n")
+	assert.NoError(t, l.Reload())
+	assert.Empty(t, l.DeprecatedKeys())
+}
+
+func TestLoad_DeprecatedKeysJSONNumbers(t *testing.T) {
+	dir := t.TempDir()
+	path := writeFile(t, dir, "config.json", `{"app_name": "legacy", "id": 9007199254740993}`)
+
+	var cfg struct {
+		Name string `json:"name" deprecated:"app_name"`
+		ID   int64  `json:"id"`
+	}
+	assert.NoError(t, NewLoader(&cfg, WithFile(path)).Load())
+	assert.Equal(t, "legacy", cfg.Name)
+	assert.Equal(t, int64(9007199254740993), cfg.ID)
+}
+
+type testVersionedConfig struct {
+	Version int `yaml:"version"`
+	Server  struct {
+		Addr string `yaml:"addr"`
+	} `yaml:"server"`
+	Timeout time.Duration `yaml:"timeout"`
+}
+
+func testMigrations() []Option {
+	return []Option{
+		// v1 -> v2: listen moved to server.addr
+		WithMigration(1, func(doc map[string]interface{}) error {
+			doc["server"] = map[string]interface{}{"addr": doc["listen"]}
+			delete(doc, "listen")
+			return nil
+		}),
+		// v2 -> v3: timeout_seconds became a duration
+		WithMigration(2, func(doc map[string]interface{}) error {
+			if secs, ok := doc["timeout_seconds"].(int); ok {
+				doc["timeout"] = (time.Duration(secs) * time.Second).String()
+				delete(doc, "timeout_seconds")
+			}
+			return nil
+		}),
+	}
+}
+
+func TestLoad_Migrations(t *testing.T) {
+	tests := []struct {
+		name string
+		doc  string
+	}{
+		{"Unversioned", "listen: :8080\ntimeout_seconds: 5\n"},
+		{"V1", "version: 1\nlisten: :8080\ntimeout_seconds: 5\n"},
+		{"V2", "version: 2\nserver:\n  addr: :8080\ntimeout_seconds: 5\n"},
+		{"V3", "version: 3\nserver:\n  addr: :8080\ntimeout: 5s\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var cfg testVersionedConfig
+			opts := append(testMigrations(), WithReader(strings.NewReader(tt.doc), FormatYAML))
+			assert.NoError(t, NewLoader(&cfg, opts...).Load())
+			assert.Equal(t, 3, cfg.Version)
+			assert.Equal(t, ":8080", cfg.Server.Addr)
+			assert.Equal(t, 5*time.Second, cfg.Timeout)
+		})
+	}
+
+	var cfg testVersionedConfig
+	opts := append(testMigrations(), WithReader(strings.NewReader("version: 4\n"), FormatYAML))
+	assert.ErrorContains(t, NewLoader(&cfg, opts...).Load(), "newer than the latest supported version 3")
+}
+
+func TestLoad_MigrationsWithFragments(t *testing.T) {
+	dir := t.TempDir()
+	path := writeFile(t, dir, "main.yaml", "version: 2\ninclude: extra.yaml\nserver:\n  addr: :9090\n")
+	writeFile(t, dir, "extra.yaml", "timeout: 5s\n")
+	writeFile(t, dir, "conf.d/10-debug.yaml", "debug: true\n")
+	writeFile(t, dir, "conf.d/20-legacy.yaml", "version: 1\nlisten: :7070\n")
+
+	var cfg struct {
+		testVersionedConfig `yaml:",inline"`
+		Debug               bool `yaml:"debug"`
+	}
+	// v1 -> v2 moves listen to server.addr, which used to default to :8080.
+	l := NewLoader(&cfg, WithFile(path), WithDir(filepath.Join(dir, "conf.d")),
+		WithMigration(1, func(doc map[string]interface{}) error {
+			addr := doc["listen"]
+			if addr == nil {
+				addr = ":8080"
+			}
+			doc["server"] = map[string]interface{}{"addr": addr}
+			delete(doc, "listen")
+			return nil
+		}))
+	assert.NoError(t, l.Load())
+	assert.True(t, cfg.Debug)
+	assert.Equal(t, 5*time.Second, cfg.Timeout)
+	assert.Equal(t, 2, cfg.Version)
+
+	// Unversioned fragments are left alone; a fragment declaring an old version is migrated.
+	assert.Equal(t, ":7070", cfg.Server.Addr)
+	assert.NoError(t, os.Remove(filepath.Join(dir, "conf.d/20-legacy.yaml")))
+	assert.NoError(t, l.Load())
+	assert.Equal(t, ":9090", cfg.Server.Addr)
+}
+
+func TestSave_YAML(t *testing.T) {
+	dir := t.TempDir()
+	encoded, _ := GenerateKey()
+	keyFile := writeFile(t, dir, "key", encoded)
+	enc, err := EncryptWithKeyFile(keyFile, "hunter2")
+	assert.NoError(t, err)
+
+	path := writeFile(t, dir, "config.yaml", `# Service settings
+include: conf.d/*.yaml
+name: "svc" # display name
+database:
+  # primary database
+  port: 5432
+  password: `+enc+`
+`)
+
+	var cfg testConfig
+	l := NewLoader(&cfg, WithFile(path), WithKeyFile(keyFile))
+	assert.NoError(t, l.Load())
+
+	cfg.Database.Port = 6543
+	cfg.Debug = true
+	cfg.Tags = []string{"blue"}
+	assert.NoError(t, l.Save())
+
+	data, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	out := string(data)
+	assert.Contains(t, out, "# Service settings")
+	assert.Contains(t, out, "include: conf.d/*.yaml")
+	assert.Contains(t, out, `name: "svc" # display name`)
+	assert.Contains(t, out, "# primary database")
+	assert.Contains(t, out, "port: 6543")
+	assert.Contains(t, out, "password: "+enc)
+	assert.NotContains(t, out, "hunter2")
+	assert.Less(t, strings.Index(out, "name:"), strings.Index(out, "database:"))
+
+	var reloaded testConfig
+	assert.NoError(t, NewLoader(&reloaded, WithFile(path), WithKeyFile(keyFile)).Load())
+	assert.Equal(t, cfg, reloaded)
+
+	entries, _ := os.ReadDir(dir)
+	assert.Len(t, entries, 2, "no temporary files should be left behind")
+}
+
+func TestSave_JSON(t *testing.T) {
+	dir := t.TempDir()
+	path := writeFile(t, dir, "config.json", `{"name": "svc"}`)
+
+	var cfg testConfig
+	l := NewLoader(&cfg, WithFile(path))
+	assert.NoError(t, l.Load())
+	cfg.Tags = []string{"a", "b"}
+	assert.NoError(t, l.Save())
+
+	var reloaded testConfig
+	assert.NoError(t, NewLoader(&reloaded, WithFile(path)).Load())
+	assert.Equal(t, cfg, reloaded)
+
+	assert.Error(t, NewLoader(&cfg).Save())
+}
+
+func TestSave_OnlyFileKeys(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "conf.d/db.yaml", "database:\n  port: 5432\n")
+	path := writeFile(t, dir, "config.yaml", "include: conf.d/*.yaml\nname: svc\n")
+	t.Setenv("TEST_DB_HOST", "db.internal")
+
+	var cfg testConfig
+	l := NewLoader(&cfg, WithFile(path))
+	assert.NoError(t, l.Load())
+	cfg.Debug = true
+	assert.NoError(t, l.Save())
+
+	data, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, "include: conf.d/*.yaml\nname: svc\ndebug: true\n", string(data))
+}
+
+func TestSave_Secrets(t *testing.T) {
+	type secretConfig struct {
+		Name  string `yaml:"name"`
+		Token string `yaml:"token" env:"TEST_SAVE_TOKEN" secret:"true"`
+	}
+	dir := t.TempDir()
+	path := writeFile(t, dir, "config.yaml", "name: svc\n")
+	t.Setenv("TEST_SAVE_TOKEN", "from-env")
+
+	var cfg secretConfig
+	l := NewLoader(&cfg, WithFile(path))
+	assert.NoError(t, l.Load())
+
+	// Secrets from env vars stay there
+	cfg.Name = "renamed"
+	assert.NoError(t, l.Save())
+	data, _ := os.ReadFile(path)
+	assert.Equal(t, "name: renamed\n", string(data))
+
+	// New secrets are not written in plaintext
+	cfg.Token = "changed"
+	err := l.Save()
+	assert.ErrorIs(t, err, errPlaintextSecret)
+	var fe *FieldError
+	assert.True(t, errors.As(err, &fe))
+	assert.Equal(t, "token", fe.Path)
+	data, _ = os.ReadFile(path)
+	assert.Equal(t, "name: renamed\n", string(data))
+
+	// With a key, they are encrypted
+	encoded, _ := GenerateKey()
+	keyFile := writeFile(t, dir, "key", encoded)
+	l = NewLoader(&cfg, WithFile(path), WithKeyFile(keyFile))
+	assert.NoError(t, l.Load())
+	cfg.Token = "changed"
+	assert.NoError(t, l.Save())
+	data, _ = os.ReadFile(path)
+	assert.NotContains(t, string(data), "changed")
+	assert.Contains(t, string(data), "token: ENC[")
+
+	os.Unsetenv("TEST_SAVE_TOKEN")
+	var reloaded secretConfig
+	assert.NoError(t, NewLoader(&reloaded, WithFile(path), WithKeyFile(keyFile)).Load())
+	assert.Equal(t, secretConfig{Name: "renamed", Token: "changed"}, reloaded)
+}
+
+func TestSave_Version(t *testing.T) {
+	dir := t.TempDir()
+	path := writeFile(t, dir, "config.json", `{"name": "svc"}`)
+
+	var cfg testConfig
+	l := NewLoader(&cfg, WithFile(path),
+		WithMigration(1, func(map[string]interface{}) error { return nil }),
+		WithMigration(2, func(map[string]interface{}) error { return nil }),
+	)
+	assert.Error(t, l.Save(), "saving before loading")
+	assert.NoError(t, l.Load())
+	assert.NoError(t, l.Save())
+
+	data, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"name": "svc", "version": 3}`, string(data))
+}
+
+func TestSave_MigratedAndRenamedKeys(t *testing.T) {
+	type legacyConfig struct {
+		Version int    `yaml:"version" json:"version"`
+		Name    string `yaml:"name" json:"name" deprecated:"app_name"`
+		Server  struct {
+			Addr string `yaml:"addr" json:"addr"`
+		} `yaml:"server" json:"server"`
+	}
+	migrate := WithMigration(1, func(doc map[string]interface{}) error {
+		doc["server"] = map[string]interface{}{"addr": doc["listen"]}
+		delete(doc, "listen")
+		return nil
+	})
+
+	tests := []struct {
+		name, file, content string
+	}{
+		{"YAML", "config.yaml", "# legacy\napp_name: svc\nlisten: :8080\n"},
+		{"JSON", "config.json", `{"app_name": "svc", "listen": ":8080"}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := writeFile(t, t.TempDir(), tt.file, tt.content)
+
+			var cfg legacyConfig
+			l := NewLoader(&cfg, WithFile(path), migrate)
+			assert.NoError(t, l.Load())
+			assert.NoError(t, l.Save())
+
+			data, err := os.ReadFile(path)
+			assert.NoError(t, err)
+			assert.NotContains(t, string(data), "listen")
+			assert.NotContains(t, string(data), "app_name")
+
+			var reloaded legacyConfig
+			rl := NewLoader(&reloaded, WithFile(path), WithStrict(), migrate)
+			assert.NoError(t, rl.Load())
+			assert.Equal(t, cfg, reloaded)
+			assert.Equal(t, 2, reloaded.Version)
+			assert.Equal(t, ":8080", reloaded.Server.Addr)
+			assert.Empty(t, rl.DeprecatedKeys())
+		})
+	}
+}
+
+func TestLoader_Concurrent(t *testing.T) {
+	dir := t.TempDir()
+	path := writeFile(t, dir, "config.yaml", "name: v0\ntags: [a]\n")
+
+	var cfg testConfig
+	l := NewLoader(&cfg, WithFile(path))
+	assert.NoError(t, l.Load())
+	l.Watch(func(c interface{}) {
+		// Listeners get their own copy and may modify it.
+		c.(*testConfig).Tags = append(c.(*testConfig).Tags, "seen")
+	})
+
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+	run := func(fn func(i int)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; ; i++ {
+				select {
+				case <-stop:
+					return
+				default:
+					fn(i)
+				}
+			}
+		}()
+	}
+
+	run(func(i int) {
+		_ = os.WriteFile(path, []byte(fmt.Sprintf("name: v%d\ntags: [a, b]\n", i)), 0644)
+	})
+	run(func(int) { _ = l.Load() })
+	run(func(int) { _ = l.Reload() })
+	run(func(int) {
+		current := l.Current().(*testConfig)
+		// Every published version is complete: defaults and file applied together.
+		assert.Equal(t, 3306, current.Database.Port)
+		assert.NotContains(t, current.Tags, "seen")
+		_ = l.History()
+		_ = l.Provenance()
+	})
+
+	time.Sleep(200 * time.Millisecond)
+	close(stop)
+	wg.Wait()
+
+	history := l.History()
+	for i := 1; i < len(history); i++ {
+		assert.Greater(t, history[i].ID, history[i-1].ID)
+	}
+}
+
+func TestBindLogLevel_Modules(t *testing.T) {
+	dir := t.TempDir()
+	path := writeFile(t, dir, "config.yaml", "log:\n  level: warn\n  modules:\n    db: debug\n    cache: error\n")
+
+	var cfg testComponentsConfig
+	l := NewLoader(&cfg, WithFile(path))
+	assert.NoError(t, l.Load())
+
+	defer log.SetLevel(log.GetLevel())
+	defer log.UnsetModuleLevel("db")
+	defer log.UnsetModuleLevel("cache")
+	assert.NoError(t, BindLogLevel(l, nil))
+	assert.Equal(t, slog.LevelWarn, log.GetLevel())
+	level, ok := log.GetModuleLevel("db")
+	assert.True(t, ok)
+	assert.Equal(t, slog.LevelDebug, level)
+
+	// Removed modules fall back to the global level.
+	writeFile(t, dir, "config.yaml", "log:\n  level: info\n  modules:\n    db: warn\n")
+	assert.NoError(t, l.Reload())
+	assert.Equal(t, slog.LevelInfo, log.GetLevel())
+	level, _ = log.GetModuleLevel("db")
+	assert.Equal(t, slog.LevelWarn, level)
+	_, ok = log.GetModuleLevel("cache")
+	assert.False(t, ok)
+
+	var bad struct{ Name string }
+	noLog := NewLoader(&bad)
+	assert.NoError(t, noLog.Load())
+	assert.Error(t, BindLogLevel(noLog, nil))
+}
+
+func TestBindLogLevel_ConcurrentRefresh(t *testing.T) {
+	dir := t.TempDir()
+	levels := []string{"debug", "info", "warn", "error"}
+	path := writeFile(t, dir, "config.yaml", "log:\n  level: debug\n")
+
+	var cfg testComponentsConfig
+	l := NewLoader(&cfg, WithFile(path))
+	assert.NoError(t, l.Load())
+	defer log.SetLevel(log.GetLevel())
+
+	// Versions applied while binding must not be lost
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 1; ; i++ {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			writeFile(t, dir, "config.yaml", "log:\n  level: "+levels[i%len(levels)]+"\n")
+			_ = l.Reload()
+		}
+	}()
+	for i := 0; i < 20; i++ {
+		assert.NoError(t, BindLogLevel(l, nil))
+	}
+	close(stop)
+	<-done
+
+	want, err := l.Current().(*testComponentsConfig).Log.SlogLevel()
+	assert.NoError(t, err)
+	assert.Equal(t, want, log.GetLevel())
+}
+
+func TestLoader_WatchLoad(t *testing.T) {
+	dir := t.TempDir()
+	path := writeFile(t, dir, "config.yaml", "name: v1\n")
+
+	var cfg testConfig
+	l := NewLoader(&cfg, WithFile(path))
+	var seen []string
+	l.Watch(func(c interface{}) {
+		seen = append(seen, c.(*testConfig).Name)
+	})
+	assert.NoError(t, l.Load())
+	assert.Equal(t, []string{"v1"}, seen)
+
+	// A version applied by Load is not applied again by the next refresh.
+	writeFile(t, dir, "config.yaml", "name: v2\n")
+	assert.NoError(t, l.Load())
+	assert.NoError(t, l.Reload())
+	assert.False(t, l.LastRefresh().Changed)
+	assert.Equal(t, []string{"v1", "v2"}, seen)
+}
+
+func TestLoader_BaseAtFirstLoad(t *testing.T) {
+	dir := t.TempDir()
+	path := writeFile(t, dir, "config.yaml", "name: svc\n")
+
+	var cfg testConfig
+	l := NewLoader(&cfg, WithFile(path))
+	cfg.Tags = []string{"preset"}
+	assert.NoError(t, l.Load())
+	assert.Equal(t, []string{"preset"}, cfg.Tags)
+
+	// Later loads start from the same contents.
+	cfg.Tags = nil
+	assert.NoError(t, l.Load())
+	assert.Equal(t, []string{"preset"}, cfg.Tags)
+}
+
+func TestLoader_CallbackReloads(t *testing.T) {
+	dir := t.TempDir()
+	path := writeFile(t, dir, "config.yaml", "name: v1\n")
+
+	var cfg testConfig
+	l := NewLoader(&cfg, WithFile(path))
+	var seen []string
+	l.Watch(func(c interface{}) {
+		name := c.(*testConfig).Name
+		seen = append(seen, name)
+		if len(seen) == 1 {
+			writeFile(t, dir, "config.yaml", "name: v2\n")
+			assert.NoError(t, l.Reload())
+			assert.NoError(t, l.Rollback(1))
+		}
+	})
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		assert.NoError(t, l.Load())
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Load deadlocked")
+	}
+	// Versions applied from a callback are delivered in order once it returns.
+	assert.Equal(t, []string{"v1", "v2", "v1"}, seen)
+}
@@ -0,0 +1,253 @@
+package config
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+)
+
+// KeySize is the size in bytes of an AES-256 encryption key.
+const KeySize = 32
+
+const (
+	encPrefix = "ENC[AES256_GCM,"
+	encSuffix = "]"
+)
+
+// WithKeyFile reads the key for decrypting ENC[...] values from a file holding a base64-encoded key.
+func WithKeyFile(path string) Option {
+	return func(l *Loader) {
+		l.keyFile = path
+	}
+}
+
+// WithKeyEnv reads the key for decrypting ENC[...] values from an environment variable
+// holding a base64-encoded key. It takes precedence over WithKeyFile when set.
+func WithKeyEnv(name string) Option {
+	return func(l *Loader) {
+		l.keyEnv = name
+	}
+}
+
+// GenerateKey returns a new random base64-encoded AES-256 key.
+func GenerateKey() (string, error) {
+	key := make([]byte, KeySize)
+	if _, err := rand.Read(key); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(key), nil
+}
+
+// ParseKey decodes a base64-encoded AES-256 key, ignoring surrounding whitespace.
+func ParseKey(s string) ([]byte, error) {
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(s))
+	if err != nil {
+		return nil, fmt.Errorf("invalid key encoding: %w", err)
+	}
+	if len(key) != KeySize {
+		return nil, fmt.Errorf("invalid key size: got %d bytes, want %d", len(key), KeySize)
+	}
+	return key, nil
+}
+
+// IsEncrypted reports whether s is an ENC[...] value.
+func IsEncrypted(s string) bool {
+	return strings.HasPrefix(s, encPrefix) && strings.HasSuffix(s, encSuffix)
+}
+
+// Encrypt encrypts plaintext with AES-256-GCM and returns it as
+// ENC[AES256_GCM,data:<base64>,iv:<base64>], ready to be put in a config file.
+func Encrypt(key []byte, plaintext string) (string, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return "", err
+	}
+
+	iv := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(iv); err != nil {
+		return "", err
+	}
+	data := gcm.Seal(nil, iv, []byte(plaintext), nil)
+
+	return fmt.Sprintf("%sdata:%s,iv:%s%s", encPrefix,
+		base64.StdEncoding.EncodeToString(data),
+		base64.StdEncoding.EncodeToString(iv),
+		encSuffix), nil
+}
+
+// Decrypt decrypts an ENC[...] value produced by Encrypt.
+func Decrypt(key []byte, value string) (string, error) {
+	if !IsEncrypted(value) {
+		return "", errors.New("value is not encrypted")
+	}
+
+	var data, iv []byte
+	body := strings.TrimSuffix(strings.TrimPrefix(value, encPrefix), encSuffix)
+	for _, part := range strings.Split(body, ",") {
+		name, enc, _ := strings.Cut(part, ":")
+		b, err := base64.StdEncoding.DecodeString(enc)
+		if err != nil {
+			return "", fmt.Errorf("invalid %s encoding: %w", name, err)
+		}
+		switch name {
+		case "data":
+			data = b
+		case "iv":
+			iv = b
+		}
+	}
+
+	gcm, err := newGCM(key)
+	if err != nil {
+		return "", err
+	}
+	if len(iv) != gcm.NonceSize() {
+		return "", fmt.Errorf("invalid iv size: %d", len(iv))
+	}
+
+	plaintext, err := gcm.Open(nil, iv, data, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt value: %w", err)
+	}
+	return string(plaintext), nil
+}
+
+// EncryptWithKeyFile encrypts a single value with the key stored in keyFile.
+// It is meant for CLI tools that prepare values for config files.
+func EncryptWithKeyFile(keyFile, plaintext string) (string, error) {
+	data, err := os.ReadFile(keyFile)
+	if err != nil {
+		return "", err
+	}
+	key, err := ParseKey(string(data))
+	if err != nil {
+		return "", err
+	}
+	return Encrypt(key, plaintext)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// decryptionKey resolves the configured key, or returns nil if none is configured.
+func (l *Loader) decryptionKey() ([]byte, error) {
+	if l.keyEnv != "" {
+		if s := os.Getenv(l.keyEnv); s != "" {
+			return ParseKey(s)
+		}
+	}
+	if l.keyFile != "" {
+		data, err := os.ReadFile(l.keyFile)
+		if err != nil {
+			return nil, err
+		}
+		return ParseKey(string(data))
+	}
+	return nil, nil
+}
+
+// errNoDecryptionKey is reported for ENC[...] values when no key is configured.
+var errNoDecryptionKey = errors.New("value is encrypted but no decryption key is configured")
+
+// processEncrypted decrypts every ENC[...] string in the config struct, recording its
+// path in result.encrypted and reporting a FieldError for each value that cannot be decrypted.
+func (l *Loader) processEncrypted(ptr interface{}, result *loadResult) error {
+	sources := result.sources
+	var key []byte
+	var keyErr error
+	keyLoaded := false
+
+	var errs []error
+	err := rewriteStrings(reflect.ValueOf(ptr).Elem(), "", func(path, s string) (string, error) {
+		if !IsEncrypted(s) {
+			return s, nil
+		}
+		if !keyLoaded {
+			key, keyErr = l.decryptionKey()
+			keyLoaded = true
+		}
+		if keyErr != nil {
+			return "", fmt.Errorf("failed to load decryption key: %w", keyErr)
+		}
+		if key == nil {
+			errs = append(errs, &FieldError{Path: path, Source: sourceOf(sources, path), Err: errNoDecryptionKey})
+			return s, nil
+		}
+		plaintext, err := Decrypt(key, s)
+		if err != nil {
+			errs = append(errs, &FieldError{Path: path, Source: sourceOf(sources, path), Err: err})
+			return s, nil
+		}
+		result.encrypted[path] = true
+		return plaintext, nil
+	})
+	if err != nil {
+		return err
+	}
+	return errors.Join(errs...)
+}
+
+// rewriteStrings calls fn for every settable string in v, including slice elements
+// and map values, and stores the result when it differs.
+func rewriteStrings(v reflect.Value, path string, fn func(path, s string) (string, error)) error {
+	switch v.Kind() {
+	case reflect.String:
+		if !v.CanSet() {
+			return nil
+		}
+		s, err := fn(path, v.String())
+		if err != nil {
+			return err
+		}
+		if s != v.String() {
+			v.SetString(s)
+		}
+	case reflect.Struct:
+		t := v.Type()
+		for i := 0; i < v.NumField(); i++ {
+			if !t.Field(i).IsExported() {
+				continue
+			}
+			if err := rewriteStrings(v.Field(i), joinPath(path, keyName(t.Field(i))), fn); err != nil {
+				return err
+			}
+		}
+	case reflect.Ptr, reflect.Interface:
+		if !v.IsNil() {
+			return rewriteStrings(v.Elem(), path, fn)
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			if err := rewriteStrings(v.Index(i), fmt.Sprintf("%s[%d]", path, i), fn); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		if v.Type().Elem().Kind() != reflect.String {
+			return nil
+		}
+		iter := v.MapRange()
+		for iter.Next() {
+			old := iter.Value().String()
+			s, err := fn(joinPath(path, fmt.Sprint(iter.Key().Interface())), old)
+			if err != nil {
+				return err
+			}
+			if s != old {
+				v.SetMapIndex(iter.Key(), reflect.ValueOf(s).Convert(v.Type().Elem()))
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+package config
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEncryptDecrypt(t *testing.T) {
+	encoded, err := GenerateKey()
+	assert.NoError(t, err)
+	key, err := ParseKey(encoded)
+	assert.NoError(t, err)
+
+	enc, err := Encrypt(key, "s3cret")
+	assert.NoError(t, err)
+	assert.True(t, IsEncrypted(enc))
+	assert.NotContains(t, enc, "s3cret")
+
+	plain, err := Decrypt(key, enc)
+	assert.NoError(t, err)
+	assert.Equal(t, "s3cret", plain)
+
+	otherEncoded, _ := GenerateKey()
+	other, _ := ParseKey(otherEncoded)
+	_, err = Decrypt(other, enc)
+	assert.Error(t, err)
+
+	_, err = ParseKey("c2hvcnQ=")
+	assert.ErrorContains(t, err, "invalid key size")
+}
+
+func TestLoad_Encrypted(t *testing.T) {
+	dir := t.TempDir()
+	encoded, _ := GenerateKey()
+	keyFile := writeFile(t, dir, "key", encoded+"\n")
+
+	enc, err := EncryptWithKeyFile(keyFile, "hunter2")
+	assert.NoError(t, err)
+	path := writeFile(t, dir, "config.yaml", "database:\n  password: "+enc+"\ntags:\n  - "+enc+"\n")
+
+	var cfg testConfig
+	assert.NoError(t, NewLoader(&cfg, WithFile(path), WithKeyFile(keyFile)).Load())
+	assert.Equal(t, "hunter2", cfg.Database.Password)
+	assert.Equal(t, []string{"hunter2"}, cfg.Tags)
+
+	// The environment variable takes precedence over the key file.
+	t.Setenv("TEST_CONFIG_KEY", encoded)
+	cfg = testConfig{}
+	assert.NoError(t, NewLoader(&cfg, WithFile(path), WithKeyEnv("TEST_CONFIG_KEY"), WithKeyFile(filepath.Join(dir, "missing"))).Load())
+	assert.Equal(t, "hunter2", cfg.Database.Password)
+
+	cfg = testConfig{}
+	err = NewLoader(&cfg, WithFile(path)).Load()
+	assert.ErrorContains(t, err, "no decryption key is configured")
+}
@@ -0,0 +1,173 @@
+package config
+
+import (
+	"encoding/json"
+	"log/slog"
+	"reflect"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// DeprecatedKey describes a deprecated key found in a config source.
+// Fields declare their old keys with a `deprecated:"old.key.path"` tag (comma-separated for several).
+type DeprecatedKey struct {
+	// Key is the deprecated key path.
+	Key string
+
+	// Replacement is the key path of the field that replaced it.
+	Replacement string
+
+	// Source is where the key was found, e.g. "file:config.yaml".
+	Source string
+}
+
+// DeprecatedKeys returns the deprecated keys used by the current version.
+func (l *Loader) DeprecatedKeys() []DeprecatedKey {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	if len(l.history) == 0 {
+		return nil
+	}
+	return append([]DeprecatedKey(nil), l.history[len(l.history)-1].result.deprecated...)
+}
+
+// unwarnedKeys returns the keys seen for the first time and marks them as warned about.
+// The caller must hold l.mu.
+func (l *Loader) unwarnedKeys(keys []DeprecatedKey) []DeprecatedKey {
+	var out []DeprecatedKey
+	for _, k := range keys {
+		if l.warnedKeys[k.Key] {
+			continue
+		}
+		if l.warnedKeys == nil {
+			l.warnedKeys = make(map[string]bool)
+		}
+		l.warnedKeys[k.Key] = true
+		out = append(out, k)
+	}
+	return out
+}
+
+// warnDeprecated logs a warning for each key. It is called without holding the
+// Loader's locks, as the log handler may be slow or read the config itself.
+func warnDeprecated(keys []DeprecatedKey) {
+	for _, k := range keys {
+		slog.Warn("config: deprecated key in use", "key", k.Key, "replacement", k.Replacement, "source", k.Source)
+	}
+}
+
+type keyAlias struct {
+	old, new string
+}
+
+// collectAliases returns the deprecated aliases declared on t and its nested structs,
+// with replacement paths made of the keys of the given document format.
+func collectAliases(t reflect.Type, prefix string, format Format) []keyAlias {
+	var aliases []keyAlias
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		key := documentKey(f, format)
+		if !f.IsExported() || key == "" {
+			continue
+		}
+		path := joinPath(prefix, key)
+
+		if tag := f.Tag.Get("deprecated"); tag != "" {
+			for _, old := range strings.Split(tag, ",") {
+				if old = strings.TrimSpace(old); old != "" {
+					aliases = append(aliases, keyAlias{old: old, new: path})
+				}
+			}
+		}
+
+		ft := f.Type
+		if ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+		if ft.Kind() == reflect.Struct {
+			aliases = append(aliases, collectAliases(ft, path, format)...)
+		}
+	}
+	return aliases
+}
+
+// applyAliases moves deprecated keys in doc to their replacement paths, unless the
+// replacement is already set, and returns the deprecated keys found. Keys of JSON
+// documents match case-insensitively, as the JSON decoder does.
+func applyAliases(t reflect.Type, doc map[string]interface{}, source string, format Format) []DeprecatedKey {
+	foldCase := format == FormatJSON
+	var used []DeprecatedKey
+	for _, a := range collectAliases(t, "", format) {
+		val, ok := getPath(doc, a.old, foldCase)
+		if !ok {
+			continue
+		}
+		deletePath(doc, a.old, foldCase)
+		if _, exists := getPath(doc, a.new, foldCase); !exists {
+			setPath(doc, a.new, val, foldCase)
+		}
+		used = append(used, DeprecatedKey{Key: a.old, Replacement: a.new, Source: source})
+	}
+	return used
+}
+
+// encodeDocument serializes doc back to data in the given format.
+func encodeDocument(doc map[string]interface{}, format Format) ([]byte, error) {
+	if format == FormatJSON {
+		return json.Marshal(doc)
+	}
+	return yaml.Marshal(doc)
+}
+
+func getPath(doc map[string]interface{}, path string, foldCase bool) (interface{}, bool) {
+	keys := strings.Split(path, ".")
+	cur := doc
+	for i, k := range keys {
+		v, ok := lookupKey(cur, k, foldCase)
+		if !ok {
+			return nil, false
+		}
+		if i == len(keys)-1 {
+			return v, true
+		}
+		if cur, ok = v.(map[string]interface{}); !ok {
+			return nil, false
+		}
+	}
+	return nil, false
+}
+
+func setPath(doc map[string]interface{}, path string, val interface{}, foldCase bool) {
+	keys := strings.Split(path, ".")
+	cur := doc
+	for _, k := range keys[:len(keys)-1] {
+		if found, ok := findKey(cur, k, foldCase); ok {
+			k = found
+		}
+		next, ok := cur[k].(map[string]interface{})
+		if !ok {
+			next = make(map[string]interface{})
+			cur[k] = next
+		}
+		cur = next
+	}
+	cur[keys[len(keys)-1]] = val
+}
+
+func deletePath(doc map[string]interface{}, path string, foldCase bool) {
+	keys := strings.Split(path, ".")
+	cur := doc
+	for _, k := range keys[:len(keys)-1] {
+		v, _ := lookupKey(cur, k, foldCase)
+		next, ok := v.(map[string]interface{})
+		if !ok {
+			return
+		}
+		cur = next
+	}
+	if k, ok := findKey(cur, keys[len(keys)-1], foldCase); ok {
+		delete(cur, k)
+	}
+}
@@ -0,0 +1,261 @@
+package config
+
+import (
+	"encoding"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ErrUnknownKey is reported in strict mode for keys that do not map to a struct field.
+var ErrUnknownKey = errors.New("unknown key")
+
+// FieldError describes a problem with a single config field.
+// Load aggregates them with errors.Join; use errors.As to inspect them.
+type FieldError struct {
+	// Path is the full dotted key path, e.g. "database.port" or "servers[1].host".
+	Path string
+
+	// Source is where the value came from: SourceDefault, SourceReader, "file:<path>" or "env:<VAR>".
+	Source string
+
+	// Value is the raw value, redacted for fields tagged `secret:"true"`.
+	Value string
+
+	// Line and Column locate the value in the source file; zero if unknown.
+	Line   int
+	Column int
+
+	// Err is the underlying error.
+	Err error
+}
+
+func (e *FieldError) Error() string {
+	var b strings.Builder
+	b.WriteString(e.Path)
+	if e.Source != "" {
+		b.WriteString(" (" + e.Source)
+		if e.Line > 0 {
+			fmt.Fprintf(&b, ":%d:%d", e.Line, e.Column)
+		}
+		b.WriteString(")")
+	}
+	if e.Value != "" {
+		fmt.Fprintf(&b, ": invalid value %q", e.Value)
+	}
+	if e.Err == nil {
+		return b.String()
+	}
+	if b.Len() > 0 {
+		b.WriteString(": ")
+	}
+	b.WriteString(e.Err.Error())
+	return b.String()
+}
+
+func (e *FieldError) Unwrap() error {
+	return e.Err
+}
+
+// newFieldError builds a FieldError for field f, redacting secret values.
+func newFieldError(path, source, value string, f reflect.StructField, err error) *FieldError {
+	if f.Tag.Get("secret") == "true" && value != "" {
+		value = redactedValue
+	}
+	return &FieldError{Path: path, Source: source, Value: value, Err: err}
+}
+
+// flattenErrors expands errors joined with errors.Join into a flat list.
+func flattenErrors(err error) []error {
+	if err == nil {
+		return nil
+	}
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		var out []error
+		for _, e := range joined.Unwrap() {
+			out = append(out, flattenErrors(e)...)
+		}
+		return out
+	}
+	return []error{err}
+}
+
+// diagnoser finds the fields responsible for a failed decode by walking the parsed
+// document alongside the struct type and decoding each value on its own.
+type diagnoser struct {
+	source    string
+	format    Format
+	strict    bool
+	positions bool // whether node positions match the original source
+	errs      []error
+}
+
+// diagnose returns one FieldError per offending value in data, or nil if none is found.
+func diagnose(data []byte, t reflect.Type, format Format, source string, strict, positions bool) []error {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil || len(doc.Content) == 0 {
+		return nil
+	}
+	d := &diagnoser{source: source, format: format, strict: strict, positions: positions}
+	d.walk(doc.Content[0], t, "", reflect.StructField{})
+	return d.errs
+}
+
+func (d *diagnoser) fail(node *yaml.Node, fe *FieldError) {
+	if d.positions {
+		fe.Line, fe.Column = node.Line, node.Column
+	}
+	d.errs = append(d.errs, fe)
+}
+
+func (d *diagnoser) walk(node *yaml.Node, t reflect.Type, path string, field reflect.StructField) {
+	if node.Kind == yaml.AliasNode {
+		node = node.Alias
+	}
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	if decodesItself(t) {
+		d.check(node, t, path, field)
+		return
+	}
+
+	switch {
+	case t.Kind() == reflect.Struct && node.Kind == yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, val := node.Content[i], node.Content[i+1]
+			f, ok := fieldByKey(t, key.Value, d.format)
+			if !ok {
+				reserved := path == "" && (key.Value == includeKey || key.Value == versionKey)
+				if d.strict && !reserved {
+					d.fail(key, &FieldError{Path: joinPath(path, key.Value), Source: d.source, Err: ErrUnknownKey})
+				}
+				continue
+			}
+			d.walk(val, f.Type, joinPath(path, key.Value), f)
+		}
+	case t.Kind() == reflect.Map && node.Kind == yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			d.walk(node.Content[i+1], t.Elem(), joinPath(path, node.Content[i].Value), field)
+		}
+	case (t.Kind() == reflect.Slice || t.Kind() == reflect.Array) && node.Kind == yaml.SequenceNode:
+		for i, item := range node.Content {
+			d.walk(item, t.Elem(), fmt.Sprintf("%s[%d]", path, i), field)
+		}
+	default:
+		d.check(node, t, path, field)
+	}
+}
+
+// check decodes a single value into a new t, the same way the format would.
+func (d *diagnoser) check(node *yaml.Node, t reflect.Type, path string, field reflect.StructField) {
+	target := reflect.New(t).Interface()
+
+	var err error
+	if d.format == FormatJSON {
+		var raw interface{}
+		if err = node.Decode(&raw); err == nil {
+			var data []byte
+			if data, err = json.Marshal(raw); err == nil {
+				err = json.Unmarshal(data, target)
+			}
+		}
+	} else {
+		err = node.Decode(target)
+	}
+	if err == nil {
+		return
+	}
+
+	var typeErr *yaml.TypeError
+	var jsonErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) || errors.As(err, &jsonErr) {
+		err = fmt.Errorf("cannot use %s as %s", describeNode(node), t)
+	}
+	value := ""
+	if node.Kind == yaml.ScalarNode {
+		value = node.Value
+	}
+	d.fail(node, newFieldError(path, d.source, value, field, err))
+}
+
+// decodesItself reports whether values of t are decoded by their own unmarshal methods.
+func decodesItself(t reflect.Type) bool {
+	p := reflect.PointerTo(t)
+	return p.Implements(reflect.TypeOf((*yaml.Unmarshaler)(nil)).Elem()) ||
+		p.Implements(reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()) ||
+		p.Implements(reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem())
+}
+
+// fieldByKey finds the field of t a key in a document of the given format maps to,
+// looking into inline structs. JSON keys match case-insensitively, as encoding/json does.
+func fieldByKey(t reflect.Type, key string, format Format) (reflect.StructField, bool) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if isInline(f, format) {
+			ft := f.Type
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if inner, ok := fieldByKey(ft, key, format); ok {
+				return inner, true
+			}
+			continue
+		}
+		if !f.IsExported() {
+			continue
+		}
+		name := documentKey(f, format)
+		if name == "" {
+			continue
+		}
+		if name == key || (format == FormatJSON && strings.EqualFold(name, key)) {
+			return f, true
+		}
+	}
+	return reflect.StructField{}, false
+}
+
+// describeNode names the kind of value in node without quoting it, as it may be a secret.
+func describeNode(node *yaml.Node) string {
+	switch node.Kind {
+	case yaml.MappingNode:
+		return "a mapping"
+	case yaml.SequenceNode:
+		return "a list"
+	}
+	switch node.ShortTag() {
+	case "!!str":
+		return "a string"
+	case "!!int":
+		return "an integer"
+	case "!!float":
+		return "a number"
+	case "!!bool":
+		return "a boolean"
+	case "!!null":
+		return "null"
+	default:
+		return "a value"
+	}
+}
+
+// sourceOf returns the source recorded for path or, for slice elements and map
+// values, for its nearest recorded parent.
+func sourceOf(sources map[string]string, path string) string {
+	for {
+		if s, ok := sources[path]; ok {
+			return s
+		}
+		i := strings.LastIndexAny(path, ".[")
+		if i < 0 {
+			return ""
+		}
+		path = path[:i]
+	}
+}
@@ -0,0 +1,165 @@
+package config
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func fieldErrors(err error) map[string]*FieldError {
+	out := make(map[string]*FieldError)
+	for _, e := range lintProblems(err) {
+		var fe *FieldError
+		if errors.As(e, &fe) {
+			out[fe.Path] = fe
+		}
+	}
+	return out
+}
+
+func TestLoad_FieldErrors(t *testing.T) {
+	dir := t.TempDir()
+	path := writeFile(t, dir, "config.yaml", "name: svc\ndatabase:\n  port: abc\n  password: [1, 2]\n")
+	t.Setenv("TEST_DB_HOST", "")
+
+	var cfg struct {
+		testConfig `yaml:",inline"`
+		Cache      struct {
+			Size ByteSize `yaml:"size" env:"TEST_CACHE_SIZE"`
+			TTL  Duration `yaml:"ttl" default:"soon"`
+		} `yaml:"cache"`
+	}
+	t.Setenv("TEST_CACHE_SIZE", "12XB")
+
+	err := NewLoader(&cfg, WithFile(path)).Load()
+	assert.Error(t, err)
+
+	errs := fieldErrors(err)
+	assert.Len(t, errs, 4)
+
+	port := errs["database.port"]
+	if assert.NotNil(t, port) {
+		assert.Equal(t, SourceFilePrefix+path, port.Source)
+		assert.Equal(t, "abc", port.Value)
+		assert.Equal(t, 3, port.Line)
+		assert.Equal(t, 9, port.Column)
+		assert.Contains(t, port.Error(), "database.port (file:"+path+":3:9): invalid value \"abc\"")
+	}
+
+	password := errs["database.password"]
+	if assert.NotNil(t, password) {
+		assert.Equal(t, 4, password.Line)
+		assert.Contains(t, password.Error(), "cannot use a list as string")
+	}
+
+	size := errs["cache.size"]
+	if assert.NotNil(t, size) {
+		assert.Equal(t, "env:TEST_CACHE_SIZE", size.Source)
+		assert.Equal(t, "12XB", size.Value)
+	}
+
+	ttl := errs["cache.ttl"]
+	if assert.NotNil(t, ttl) {
+		assert.Equal(t, SourceDefault, ttl.Source)
+		assert.Equal(t, "soon", ttl.Value)
+	}
+}
+
+func TestLoad_FieldErrorsSecretAndJSON(t *testing.T) {
+	t.Setenv("TEST_DB_HOST", "")
+	var cfg struct {
+		Token int  `json:"token" secret:"true"`
+		Debug bool `json:"debug"`
+	}
+	err := NewLoader(&cfg, WithReader(strings.NewReader(`{"token": "hunter2", "debug": "yes"}`), FormatJSON)).Load()
+
+	errs := fieldErrors(err)
+	assert.Len(t, errs, 2)
+	if assert.NotNil(t, errs["token"]) {
+		assert.Equal(t, redactedValue, errs["token"].Value)
+		assert.Equal(t, SourceReader, errs["token"].Source)
+		assert.NotContains(t, err.Error(), "hunter2")
+	}
+	if assert.NotNil(t, errs["debug"]) {
+		assert.Equal(t, 1, errs["debug"].Line)
+	}
+}
+
+func TestLoad_FieldErrorsUnknownKeys(t *testing.T) {
+	var cfg testConfig
+	data := "name: svc\nnmae: typo\ndatabase:\n  prot: 1\n"
+	err := NewLoader(&cfg, WithReader(strings.NewReader(data), FormatYAML), WithStrict()).Load()
+
+	assert.ErrorIs(t, err, ErrUnknownKey)
+	errs := fieldErrors(err)
+	assert.Len(t, errs, 2)
+	if assert.NotNil(t, errs["database.prot"]) {
+		assert.Equal(t, 4, errs["database.prot"].Line)
+		assert.Empty(t, errs["database.prot"].Value)
+	}
+	assert.NotNil(t, errs["nmae"])
+}
+
+func TestLoad_FieldErrorsTagPerFormat(t *testing.T) {
+	var cfg struct {
+		Host string `yaml:"db_host" json:"dbHost"`
+		Port int    `yaml:"db_port" json:"dbPort"`
+		testEmbedded
+	}
+
+	// JSON documents use the json tags, and embedded structs are flattened as by encoding/json
+	data := `{"dbHost": "db.internal", "db_port": 5432, "level": 3}`
+	err := NewLoader(&cfg, WithReader(strings.NewReader(data), FormatJSON), WithStrict()).Load()
+	assert.ErrorIs(t, err, ErrUnknownKey)
+	errs := fieldErrors(err)
+	assert.Len(t, errs, 1)
+	assert.NotNil(t, errs["db_port"])
+
+	data = "db_host: db.internal\ndbPort: 5432\n"
+	err = NewLoader(&cfg, WithReader(strings.NewReader(data), FormatYAML), WithStrict()).Load()
+	errs = fieldErrors(err)
+	assert.Len(t, errs, 1)
+	assert.NotNil(t, errs["dbPort"])
+}
+
+// testEmbedded is flattened by encoding/json, but nested by yaml.v3 without ",inline".
+type testEmbedded struct {
+	Level int `json:"level"`
+}
+
+func TestLoad_FieldErrorsReservedKeysKeepPositions(t *testing.T) {
+	for _, strict := range []bool{false, true} {
+		var cfg struct {
+			Port int `yaml:"port"`
+		}
+		opts := []Option{WithReader(strings.NewReader("version: 1\nport: abc\n"), FormatYAML)}
+		if strict {
+			opts = append(opts, WithStrict())
+		}
+		errs := fieldErrors(NewLoader(&cfg, opts...).Load())
+		if assert.NotNil(t, errs["port"], "strict=%v", strict) {
+			assert.Equal(t, 2, errs["port"].Line, "strict=%v", strict)
+			assert.Equal(t, 7, errs["port"].Column, "strict=%v", strict)
+		}
+	}
+}
+
+func TestValidate_CollectsAll(t *testing.T) {
+	var cfg struct {
+		A testLintConfig `yaml:"a"`
+		B testLintConfig `yaml:"b"`
+	}
+	err := NewLoader(&cfg, WithReader(strings.NewReader("a:\n  port: 0\n"), FormatYAML), WithValidation()).Load()
+
+	errs := fieldErrors(err)
+	assert.Len(t, errs, 2)
+	assert.Contains(t, errs, "a")
+	assert.Contains(t, errs, "b")
+}
+
+func TestFieldError_NilErr(t *testing.T) {
+	assert.Equal(t, "db.port (env:DB_PORT)", (&FieldError{Path: "db.port", Source: "env:DB_PORT"}).Error())
+	assert.Equal(t, "", (&FieldError{}).Error())
+}
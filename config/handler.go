@@ -0,0 +1,129 @@
+package config
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"time"
+)
+
+// redactedValue replaces secret and encrypted strings wherever a version is shown.
+const redactedValue = "******"
+
+type handlerStatus struct {
+	Time    *time.Time `json:"time,omitempty"`
+	Changed bool       `json:"changed"`
+	Error   string     `json:"error,omitempty"`
+}
+
+type handlerVersion struct {
+	ID        int       `json:"id"`
+	AppliedAt time.Time `json:"applied_at"`
+	Hash      string    `json:"hash"`
+}
+
+type handlerResponse struct {
+	Config      interface{}       `json:"config"`
+	Provenance  map[string]string `json:"provenance"`
+	LastRefresh handlerStatus     `json:"last_refresh"`
+	History     []handlerVersion  `json:"history"`
+}
+
+// NewHandler returns an http.Handler for inspecting the Loader on a running service.
+//
+// GET returns the current effective config (with fields tagged `secret:"true"` and values
+// decrypted from ENC[...] redacted),
+// the provenance of each field, the last refresh status and the version history as JSON.
+// POST triggers Reload and returns the same document, with status 500 if the reload failed.
+func NewHandler(l *Loader) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		status := http.StatusOK
+		switch r.Method {
+		case http.MethodGet, http.MethodHead:
+		case http.MethodPost:
+			if err := l.Reload(); err != nil {
+				status = http.StatusInternalServerError
+			}
+		default:
+			w.Header().Set("Allow", "GET, HEAD, POST")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		_ = enc.Encode(l.debugState())
+	})
+}
+
+func (l *Loader) debugState() handlerResponse {
+	resp := handlerResponse{
+		Provenance: l.Provenance(),
+		History:    []handlerVersion{},
+	}
+
+	history := l.History()
+	for _, v := range history {
+		resp.History = append(resp.History, handlerVersion{ID: v.ID, AppliedAt: v.AppliedAt, Hash: v.Hash})
+	}
+	if len(history) > 0 {
+		resp.Config = history[len(history)-1].Config
+	}
+
+	st := l.LastRefresh()
+	resp.LastRefresh.Changed = st.Changed
+	if !st.Time.IsZero() {
+		resp.LastRefresh.Time = &st.Time
+	}
+	if st.Err != nil {
+		resp.LastRefresh.Error = st.Err.Error()
+	}
+	return resp
+}
+
+// redact returns a copy of cfg with fields tagged `secret:"true"` and the values at the
+// encrypted paths masked. Non-empty secret strings and encrypted values become
+// redactedValue; other secret fields are zeroed.
+func redact(cfg interface{}, encrypted map[string]bool) interface{} {
+	c := cloneConfig(cfg)
+	v := reflect.ValueOf(c)
+	if v.Kind() == reflect.Ptr && !v.IsNil() && v.Elem().Kind() == reflect.Struct {
+		redactStruct(v.Elem())
+		if len(encrypted) > 0 {
+			_ = rewriteStrings(v.Elem(), "", func(path, s string) (string, error) {
+				if encrypted[path] && s != "" {
+					return redactedValue, nil
+				}
+				return s, nil
+			})
+		}
+	}
+	return c
+}
+
+func redactStruct(v reflect.Value) {
+	t := v.Type()
+	for i := 0; i < v.NumField(); i++ {
+		fieldVal := v.Field(i)
+		if !fieldVal.CanSet() {
+			continue
+		}
+
+		if t.Field(i).Tag.Get("secret") == "true" {
+			if fieldVal.Kind() == reflect.String && fieldVal.Len() > 0 {
+				fieldVal.SetString(redactedValue)
+			} else {
+				fieldVal.Set(reflect.Zero(fieldVal.Type()))
+			}
+			continue
+		}
+
+		if fieldVal.Kind() == reflect.Struct {
+			redactStruct(fieldVal)
+		} else if fieldVal.Kind() == reflect.Ptr && !fieldVal.IsNil() && fieldVal.Elem().Kind() == reflect.Struct {
+			redactStruct(fieldVal.Elem())
+		}
+	}
+}
@@ -0,0 +1,154 @@
+package config
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHandler(t *testing.T) {
+	dir := t.TempDir()
+	path := writeFile(t, dir, "config.yaml", "name: v1\ndatabase:\n  password: hunter2\n")
+	t.Setenv("TEST_DB_HOST", "db.internal")
+
+	var cfg testConfig
+	l := NewLoader(&cfg, WithFile(path))
+	assert.NoError(t, l.Load())
+	h := NewHandler(l)
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.NotContains(t, rec.Body.String(), "hunter2")
+
+	var resp struct {
+		Config struct {
+			Name     string `json:"name"`
+			Database struct {
+				Password string `json:"password"`
+			} `json:"database"`
+		} `json:"config"`
+		Provenance  map[string]string `json:"provenance"`
+		LastRefresh struct {
+			Changed bool   `json:"changed"`
+			Error   string `json:"error"`
+		} `json:"last_refresh"`
+		History []struct {
+			ID int `json:"id"`
+		} `json:"history"`
+	}
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	assert.Equal(t, "v1", resp.Config.Name)
+	assert.Equal(t, redactedValue, resp.Config.Database.Password)
+	assert.Equal(t, SourceFilePrefix+path, resp.Provenance["name"])
+	assert.Equal(t, SourceDefault, resp.Provenance["database.port"])
+	assert.Equal(t, SourceEnvPrefix+"TEST_DB_HOST", resp.Provenance["database.host"])
+	assert.Len(t, resp.History, 1)
+
+	// POST reloads and applies the changed file.
+	writeFile(t, dir, "config.yaml", "name: v2\n")
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	assert.Equal(t, "v2", resp.Config.Name)
+	assert.True(t, resp.LastRefresh.Changed)
+	assert.Len(t, resp.History, 2)
+
+	// A failed reload is reported and keeps the current version.
+	writeFile(t, dir, "config.yaml", "name: [broken\n")
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/", nil))
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	assert.Equal(t, "v2", resp.Config.Name)
+	assert.NotEmpty(t, resp.LastRefresh.Error)
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodDelete, "/", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+}
+
+func TestHandler_RedactsEncrypted(t *testing.T) {
+	dir := t.TempDir()
+	encoded, _ := GenerateKey()
+	keyFile := writeFile(t, dir, "key", encoded)
+	enc, err := EncryptWithKeyFile(keyFile, "hunter2")
+	assert.NoError(t, err)
+	path := writeFile(t, dir, "config.yaml", "name: "+enc+"\ntags:\n  - plain\n  - "+enc+"\n")
+
+	var cfg testConfig
+	l := NewLoader(&cfg, WithFile(path), WithKeyFile(keyFile))
+	assert.NoError(t, l.Load())
+	assert.Equal(t, "hunter2", l.Current().(*testConfig).Name)
+
+	rec := httptest.NewRecorder()
+	NewHandler(l).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.NotContains(t, rec.Body.String(), "hunter2")
+
+	var resp struct {
+		Config struct {
+			Name string   `json:"name"`
+			Tags []string `json:"tags"`
+		} `json:"config"`
+	}
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	assert.Equal(t, redactedValue, resp.Config.Name)
+	assert.Equal(t, []string{"plain", redactedValue}, resp.Config.Tags)
+
+	// History and Diff mask them too.
+	writeFile(t, dir, "config.yaml", "name: "+enc+"\ntags: [changed]\n")
+	assert.NoError(t, l.Reload())
+	for _, v := range l.History() {
+		assert.Equal(t, redactedValue, v.Config.(*testConfig).Name)
+	}
+	diff, err := l.Diff(1, 2)
+	assert.NoError(t, err)
+	assert.Contains(t, diff, "changed")
+	assert.NotContains(t, diff, "hunter2")
+}
+
+func TestProvenance_KeyCase(t *testing.T) {
+	dir := t.TempDir()
+
+	// YAML keys are case-sensitive, so "Name" does not set name.
+	path := writeFile(t, dir, "config.yaml", "Name: other\n")
+	var cfg testConfig
+	l := NewLoader(&cfg, WithFile(path))
+	assert.NoError(t, l.Load())
+	assert.Equal(t, "app", cfg.Name)
+	assert.Equal(t, SourceDefault, l.Provenance()["name"])
+
+	// The JSON decoder matches keys case-insensitively.
+	path = writeFile(t, dir, "config.json", `{"NAME": "other"}`)
+	cfg = testConfig{}
+	l = NewLoader(&cfg, WithFile(path))
+	assert.NoError(t, l.Load())
+	assert.Equal(t, "other", cfg.Name)
+	assert.Equal(t, SourceFilePrefix+path, l.Provenance()["name"])
+}
+
+func TestProvenance_TagPerFormat(t *testing.T) {
+	type tagged struct {
+		Host string `yaml:"db_host" json:"dbHost"`
+		Port int    `yaml:"db_port" json:"dbPort"`
+	}
+	dir := t.TempDir()
+
+	// Each format matches its own tag, but key paths always use the yaml tag.
+	for _, file := range []struct{ name, content string }{
+		{"c.json", `{"dbHost": "db.internal", "dbPort": 5432}`},
+		{"c.yaml", "db_host: db.internal\ndb_port: 5432\n"},
+	} {
+		path := writeFile(t, dir, file.name, file.content)
+		var cfg tagged
+		l := NewLoader(&cfg, WithFile(path))
+		assert.NoError(t, l.Load(), file.name)
+		assert.Equal(t, tagged{Host: "db.internal", Port: 5432}, cfg)
+		assert.Equal(t, SourceFilePrefix+path, l.Provenance()["db_host"], file.name)
+	}
+}
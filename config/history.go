@@ -0,0 +1,271 @@
+package config
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/fuguiw/fg-lib/utils"
+)
+
+// DefaultHistorySize is the number of applied versions kept when WithHistory is not used.
+const DefaultHistorySize = 10
+
+// Version is a snapshot of an applied configuration.
+type Version struct {
+	// ID increases monotonically with every applied version, starting at 1.
+	ID int
+
+	// AppliedAt is the time the version was applied.
+	AppliedAt time.Time
+
+	// Hash is the hex-encoded SHA-256 of the configuration's contents.
+	Hash string
+
+	// Config is a copy of the applied configuration struct (a pointer of the same type as
+	// the Loader's), with fields tagged `secret:"true"` and values decrypted from ENC[...]
+	// masked. Use Current for the values in effect and Rollback to re-apply a version.
+	Config interface{}
+
+	result *loadResult
+}
+
+// WithHistory sets how many applied versions the Loader keeps.
+func WithHistory(size int) Option {
+	return func(l *Loader) {
+		l.historySize = size
+	}
+}
+
+// History returns the applied versions, oldest first.
+func (l *Loader) History() []Version {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	out := make([]Version, len(l.history))
+	for i, v := range l.history {
+		out[i] = v.masked()
+	}
+	return out
+}
+
+// Rollback re-applies the version with the given ID and notifies the update callbacks.
+// The rollback is recorded as a new version; it stays in effect until the sources change again.
+func (l *Loader) Rollback(id int) error {
+	defer l.deliver()
+	l.loadMu.Lock()
+	defer l.loadMu.Unlock()
+
+	l.mu.Lock()
+	v, ok := l.findVersion(id)
+	if !ok {
+		l.mu.Unlock()
+		return fmt.Errorf("config version %d not found", id)
+	}
+	cfg := v.Config
+	l.record(cfg, v.Hash, v.result)
+	l.current.Store(&cfg)
+	l.enqueue(cfg)
+	l.mu.Unlock()
+
+	return nil
+}
+
+// Diff renders the difference between two versions using utils.ShowJsonDiff.
+func (l *Loader) Diff(fromID, toID int) (string, error) {
+	l.mu.RLock()
+	from, okFrom := l.findVersion(fromID)
+	to, okTo := l.findVersion(toID)
+	l.mu.RUnlock()
+
+	if !okFrom {
+		return "", fmt.Errorf("config version %d not found", fromID)
+	}
+	if !okTo {
+		return "", fmt.Errorf("config version %d not found", toID)
+	}
+	return utils.ShowJsonDiff(from.masked().Config, to.masked().Config)
+}
+
+// masked returns v with secret and encrypted values in its config masked.
+func (v Version) masked() Version {
+	v.Config = redact(v.Config, v.result.encrypted)
+	return v
+}
+
+// record appends cfg to the history, dropping the oldest versions beyond the limit.
+// The caller must hold l.mu.
+func (l *Loader) record(cfg interface{}, hash string, result *loadResult) {
+	l.lastVersionID++
+	l.history = append(l.history, Version{
+		ID:        l.lastVersionID,
+		AppliedAt: time.Now(),
+		Hash:      hash,
+		Config:    cfg,
+		result:    result,
+	})
+
+	size := l.historySize
+	if size <= 0 {
+		size = DefaultHistorySize
+	}
+	if n := len(l.history) - size; n > 0 {
+		l.history = append([]Version(nil), l.history[n:]...)
+	}
+}
+
+// findVersion looks up a version by ID. The caller must hold l.mu.
+func (l *Loader) findVersion(id int) (Version, bool) {
+	for _, v := range l.history {
+		if v.ID == id {
+			return v, true
+		}
+	}
+	return Version{}, false
+}
+
+// hashConfig returns the hex-encoded SHA-256 of the config's contents. It walks the value
+// with reflection rather than encoding it, so every field counts, including fields that
+// are skipped by or cannot be encoded in JSON or YAML.
+func hashConfig(cfg interface{}) string {
+	h := sha256.New()
+	hashValue(h, reflect.ValueOf(cfg), make(map[uintptr]bool))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// hashValue writes v to w, prefixed with its kind so that different values never
+// produce the same bytes. seen guards against pointer cycles.
+func hashValue(w io.Writer, v reflect.Value, seen map[uintptr]bool) {
+	if !v.IsValid() {
+		io.WriteString(w, "invalid;")
+		return
+	}
+	fmt.Fprintf(w, "%s:", v.Kind())
+
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			io.WriteString(w, "nil;")
+			return
+		}
+		if seen[v.Pointer()] {
+			io.WriteString(w, "cycle;")
+			return
+		}
+		seen[v.Pointer()] = true
+		hashValue(w, v.Elem(), seen)
+		delete(seen, v.Pointer())
+	case reflect.Interface:
+		if v.IsNil() {
+			io.WriteString(w, "nil;")
+			return
+		}
+		fmt.Fprintf(w, "%s:", v.Elem().Type())
+		hashValue(w, v.Elem(), seen)
+	case reflect.Struct:
+		fmt.Fprintf(w, "%d{", v.NumField())
+		for i := 0; i < v.NumField(); i++ {
+			hashValue(w, v.Field(i), seen)
+		}
+		io.WriteString(w, "}")
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			io.WriteString(w, "nil;")
+			return
+		}
+		fmt.Fprintf(w, "%d[", v.Len())
+		for i := 0; i < v.Len(); i++ {
+			hashValue(w, v.Index(i), seen)
+		}
+		io.WriteString(w, "]")
+	case reflect.Map:
+		if v.IsNil() {
+			io.WriteString(w, "nil;")
+			return
+		}
+		// Hash each entry on its own and sort them, as map order is random.
+		entries := make([]string, 0, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			var b strings.Builder
+			hashValue(&b, iter.Key(), seen)
+			hashValue(&b, iter.Value(), seen)
+			entries = append(entries, b.String())
+		}
+		sort.Strings(entries)
+		fmt.Fprintf(w, "%d{", len(entries))
+		for _, e := range entries {
+			io.WriteString(w, e)
+		}
+		io.WriteString(w, "}")
+	case reflect.Func, reflect.Chan, reflect.UnsafePointer:
+		// Only identity can be compared.
+		fmt.Fprintf(w, "%x;", v.Pointer())
+	case reflect.String:
+		fmt.Fprintf(w, "%d:%s;", v.Len(), v.String())
+	default:
+		// Numbers, bools and complex numbers; Fprint works on unexported fields too.
+		fmt.Fprintf(w, "%v;", v)
+	}
+}
+
+// cloneConfig returns a deep copy of the config pointer.
+func cloneConfig(cfg interface{}) interface{} {
+	return deepCopy(reflect.ValueOf(cfg)).Interface()
+}
+
+func deepCopy(v reflect.Value) reflect.Value {
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			return v
+		}
+		c := reflect.New(v.Elem().Type())
+		c.Elem().Set(deepCopy(v.Elem()))
+		return c
+	case reflect.Struct:
+		// Copy the whole struct first so unexported fields are preserved,
+		// then replace exported reference fields with their own copies.
+		c := reflect.New(v.Type()).Elem()
+		c.Set(v)
+		for i := 0; i < v.NumField(); i++ {
+			if c.Field(i).CanSet() {
+				c.Field(i).Set(deepCopy(v.Field(i)))
+			}
+		}
+		return c
+	case reflect.Slice:
+		if v.IsNil() {
+			return v
+		}
+		c := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		for i := 0; i < v.Len(); i++ {
+			c.Index(i).Set(deepCopy(v.Index(i)))
+		}
+		return c
+	case reflect.Map:
+		if v.IsNil() {
+			return v
+		}
+		c := reflect.MakeMapWithSize(v.Type(), v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			c.SetMapIndex(iter.Key(), deepCopy(iter.Value()))
+		}
+		return c
+	case reflect.Interface:
+		if v.IsNil() {
+			return v
+		}
+		c := reflect.New(v.Type()).Elem()
+		c.Set(deepCopy(v.Elem()))
+		return c
+	default:
+		return v
+	}
+}
@@ -0,0 +1,102 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHistory_RefreshAndRollback(t *testing.T) {
+	dir := t.TempDir()
+	path := writeFile(t, dir, "config.yaml", "name: v1\n")
+
+	var cfg testConfig
+	l := NewLoader(&cfg, WithFile(path), WithHistory(3))
+	assert.NoError(t, l.Load())
+
+	var applied []string
+	l.onUpdateFunc = func(c interface{}) {
+		applied = append(applied, c.(*testConfig).Name)
+	}
+
+	// Unchanged sources are not re-applied.
+	l.refresh()
+	assert.Empty(t, applied)
+
+	writeFile(t, dir, "config.yaml", "name: v2\n")
+	l.refresh()
+	assert.Equal(t, []string{"v2"}, applied)
+
+	history := l.History()
+	assert.Len(t, history, 2)
+	assert.Equal(t, 1, history[0].ID)
+	assert.Equal(t, "v1", history[0].Config.(*testConfig).Name)
+	assert.NotEqual(t, history[0].Hash, history[1].Hash)
+
+	diff, err := l.Diff(1, 2)
+	assert.NoError(t, err)
+	assert.Contains(t, diff, "v2")
+
+	assert.NoError(t, l.Rollback(1))
+	assert.Equal(t, []string{"v2", "v1"}, applied)
+
+	history = l.History()
+	assert.Len(t, history, 3)
+	assert.Equal(t, history[0].Hash, history[2].Hash)
+
+	// The rollback sticks until the file changes again.
+	l.refresh()
+	assert.Equal(t, []string{"v2", "v1"}, applied)
+
+	writeFile(t, dir, "config.yaml", "name: v3\n")
+	l.refresh()
+	assert.Equal(t, []string{"v2", "v1", "v3"}, applied)
+
+	// Oldest versions are dropped beyond the limit.
+	history = l.History()
+	assert.Len(t, history, 3)
+	assert.Equal(t, 2, history[0].ID)
+
+	assert.Error(t, l.Rollback(1))
+	_, err = l.Diff(1, 4)
+	assert.Error(t, err)
+}
+
+func TestCloneConfig(t *testing.T) {
+	src := &testConfig{Name: "a", Tags: []string{"x"}}
+	dst := cloneConfig(src).(*testConfig)
+	dst.Tags[0] = "y"
+	dst.Name = "b"
+
+	assert.Equal(t, "a", src.Name)
+	assert.Equal(t, []string{"x"}, src.Tags)
+}
+
+func TestHistory_HashCoversAllFields(t *testing.T) {
+	type hashConfig struct {
+		Name     string            `yaml:"name"`
+		Internal string            `json:"-" yaml:"-" env:"TEST_INTERNAL"`
+		Labels   map[string]string `yaml:"labels"`
+		OnChange func()            `yaml:"-"`
+	}
+	dir := t.TempDir()
+	path := writeFile(t, dir, "config.yaml", "name: svc\nlabels: {a: '1', b: '2', c: '3'}\n")
+	t.Setenv("TEST_INTERNAL", "one")
+
+	// Fields JSON cannot encode do not fail the load.
+	cfg := hashConfig{OnChange: func() {}}
+	l := NewLoader(&cfg, WithFile(path))
+	assert.NoError(t, l.Load())
+
+	// Map order does not change the hash.
+	for i := 0; i < 5; i++ {
+		assert.NoError(t, l.Reload())
+		assert.False(t, l.LastRefresh().Changed)
+	}
+
+	// Fields skipped by JSON still count as changes.
+	t.Setenv("TEST_INTERNAL", "two")
+	assert.NoError(t, l.Reload())
+	assert.True(t, l.LastRefresh().Changed)
+	assert.Equal(t, "two", l.Current().(*hashConfig).Internal)
+}
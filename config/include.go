@@ -0,0 +1,244 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+
+	"gopkg.in/yaml.v3"
+)
+
+// includeKey is the top-level key holding include directives in a config file.
+const includeKey = "include"
+
+// errReaderInclude is reported for include directives in a WithReader source.
+var errReaderInclude = errors.New("include directives are not supported in reader sources")
+
+// WithDir specifies a directory (e.g. conf.d) whose *.yaml, *.yml and *.json files
+// are loaded in lexical order after the main config file.
+func WithDir(dir string) Option {
+	return func(l *Loader) {
+		l.configDir = dir
+	}
+}
+
+// fileLoader carries the state of loading config files into one struct.
+type fileLoader struct {
+	fsys    sourceFS
+	ptr     interface{}
+	result  *loadResult
+	visited map[string]bool
+
+	migrations map[int]Migration
+	strict     bool
+
+	// errs collects problems so the remaining files are still loaded.
+	errs []error
+}
+
+func (fl *fileLoader) fail(err error) {
+	fl.errs = append(fl.errs, err)
+}
+
+// loadFiles loads the reader, the configured file and directory into ptr.
+func (l *Loader) loadFiles(ptr interface{}, result *loadResult) error {
+	fl := &fileLoader{
+		fsys:    l.sourceFS(),
+		ptr:     ptr,
+		result:  result,
+		visited: make(map[string]bool),
+
+		migrations: l.migrations,
+		strict:     l.strict,
+	}
+
+	if l.reader != nil {
+		if data, err := l.readerData(); err != nil {
+			fl.fail(fmt.Errorf("%s: %w", SourceReader, err))
+		} else {
+			fl.loadData(SourceReader, SourceReader, data, l.readerFormat, true)
+		}
+	}
+	if l.configFile != "" {
+		fl.loadFile(l.configFile, true)
+	}
+	if l.configDir != "" {
+		fl.loadDir(l.configDir)
+	}
+	return errors.Join(fl.errs...)
+}
+
+// loadDir loads every supported config file in dir in lexical order.
+func (fl *fileLoader) loadDir(dir string) {
+	entries, err := fl.fsys.ReadDir(dir)
+	if err != nil {
+		fl.fail(err)
+		return
+	}
+
+	var files []string
+	for _, e := range entries {
+		if e.IsDir() || !isConfigFile(e.Name()) {
+			continue
+		}
+		files = append(files, fl.fsys.Join(dir, e.Name()))
+	}
+	sort.Strings(files)
+
+	for _, f := range files {
+		fl.loadFile(f, false)
+	}
+}
+
+// loadFile loads path into the struct, then every file matched by its include
+// directives, so included files override the including one. main is set for the
+// WithFile document, as opposed to included and conf.d fragments.
+func (fl *fileLoader) loadFile(path string, main bool) {
+	id, err := fl.fsys.ID(path)
+	if err != nil {
+		fl.fail(fmt.Errorf("%s: %w", path, err))
+		return
+	}
+	if fl.visited[id] {
+		fl.fail(fmt.Errorf("%s: include cycle detected", path))
+		return
+	}
+	fl.visited[id] = true
+	defer delete(fl.visited, id)
+
+	// If file doesn't exist and we just want to use defaults/env, maybe ignore?
+	// Requirement implies "Support file config", usually if file is specified but missing, it's an error.
+	data, err := fl.fsys.ReadFile(path)
+	if err != nil {
+		fl.fail(err)
+		return
+	}
+
+	format, err := formatOf(path)
+	if err != nil {
+		fl.fail(fmt.Errorf("%s: %w", path, err))
+		return
+	}
+	fl.loadData(path, SourceFilePrefix+path, data, format, main)
+}
+
+// loadData decodes data into the struct and follows its include directives,
+// which are resolved relative to path.
+func (fl *fileLoader) loadData(path, source string, data []byte, format Format, main bool) {
+	doc, err := parseDocument(data, format)
+	if err != nil {
+		fl.fail(fmt.Errorf("%s: %w", path, err))
+		return
+	}
+
+	// Upgrade older formats, then move deprecated keys to their replacements.
+	// Fragments are only migrated if they declare a version, as a fragment without
+	// one is usually written for the current format rather than version 1.
+	changed := false
+	if _, versioned := doc[versionKey]; main || versioned {
+		if changed, err = migrateDocument(doc, fl.migrations); err != nil {
+			fl.fail(fmt.Errorf("%s: %w", path, err))
+			return
+		}
+	}
+	t := reflect.TypeOf(fl.ptr).Elem()
+	if used := applyAliases(t, doc, source, format); len(used) > 0 {
+		fl.result.deprecated = append(fl.result.deprecated, used...)
+		changed = true
+	}
+	// The strict decoders reject the reserved keys, but diagnose allows them, so
+	// problems in a document that was only stripped are found in the original bytes.
+	original := data
+	stripped := fl.strict && stripReserved(t, doc, format)
+	if changed || stripped {
+		if data, err = encodeDocument(doc, format); err != nil {
+			fl.fail(fmt.Errorf("%s: %w", path, err))
+			return
+		}
+	}
+
+	// Decoding carries on past bad values, so report each of them and keep going
+	if err := decode(data, format, fl.ptr, fl.strict); err != nil {
+		diagData := original
+		if changed {
+			diagData = data
+		}
+		if errs := diagnose(diagData, t, format, source, fl.strict, !changed); len(errs) > 0 {
+			fl.errs = append(fl.errs, errs...)
+		} else {
+			fl.fail(fmt.Errorf("%s: %w", path, err))
+		}
+	}
+	markFileSources(t, doc, "", source, fl.result.sources, format)
+
+	patterns, err := parseIncludes(doc)
+	if err != nil {
+		fl.fail(fmt.Errorf("%s: %w", path, err))
+		return
+	}
+
+	// A reader has no location to resolve relative patterns against
+	if len(patterns) > 0 && source == SourceReader {
+		fl.fail(fmt.Errorf("%s: %w", path, errReaderInclude))
+		return
+	}
+
+	for _, pattern := range patterns {
+		pattern = fl.fsys.Resolve(path, pattern)
+		matches, err := fl.fsys.Glob(pattern)
+		if err != nil {
+			fl.fail(fmt.Errorf("%s: invalid include pattern %q: %w", path, pattern, err))
+			continue
+		}
+		sort.Strings(matches)
+		for _, m := range matches {
+			fl.loadFile(m, false)
+		}
+	}
+}
+
+// parseDocument parses data into a generic document. JSON numbers are kept as
+// json.Number, so integers beyond float64 precision survive re-encoding.
+func parseDocument(data []byte, format Format) (map[string]interface{}, error) {
+	var doc map[string]interface{}
+	var err error
+	switch format {
+	case FormatJSON:
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.UseNumber()
+		err = dec.Decode(&doc)
+	default:
+		err = yaml.Unmarshal(data, &doc)
+	}
+	return doc, err
+}
+
+// parseIncludes extracts the include directive, which may be a single pattern or a list.
+func parseIncludes(doc map[string]interface{}) ([]string, error) {
+	switch v := doc[includeKey].(type) {
+	case nil:
+		return nil, nil
+	case string:
+		return []string{v}, nil
+	case []interface{}:
+		patterns := make([]string, 0, len(v))
+		for _, item := range v {
+			s, ok := item.(string)
+			if !ok {
+				return nil, fmt.Errorf("include entries must be strings, got %T", item)
+			}
+			patterns = append(patterns, s)
+		}
+		return patterns, nil
+	default:
+		return nil, fmt.Errorf("include must be a string or a list of strings, got %T", v)
+	}
+}
+
+func isConfigFile(name string) bool {
+	_, err := formatOf(name)
+	return err == nil
+}
@@ -0,0 +1,147 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/fuguiw/fg-lib/utils"
+)
+
+var (
+	schemasMu sync.RWMutex
+	schemas   = make(map[string]reflect.Type)
+)
+
+// RegisterSchema registers the type of cfg (a struct or pointer to struct) under name,
+// so Lint and RunLint can check config files against it.
+func RegisterSchema(name string, cfg interface{}) {
+	t := reflect.TypeOf(cfg)
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		panic(fmt.Sprintf("config: schema %q must be a struct, got %s", name, t))
+	}
+
+	schemasMu.Lock()
+	defer schemasMu.Unlock()
+	schemas[name] = t
+}
+
+// Lint loads file against the schema registered under name with WithStrict and
+// WithValidation, without starting anything. Extra options (e.g. WithKeyFile) are applied too.
+func Lint(name, file string, opts ...Option) error {
+	schemasMu.RLock()
+	t, ok := schemas[name]
+	schemasMu.RUnlock()
+	if !ok {
+		return fmt.Errorf("schema %q is not registered", name)
+	}
+
+	cfg := reflect.New(t).Interface()
+	opts = append([]Option{WithFile(file), WithStrict(), WithValidation()}, opts...)
+	return NewLoader(cfg, opts...).Load()
+}
+
+// RunLint implements a config-lint command on top of Lint. It parses args
+// (without the program name), prints the result of each file as a table to stdout,
+// usage errors to stderr, and returns the exit code: 0 if all files are valid, 1 if
+// any is not and 2 on usage errors. Applications register their schemas and call it from main:
+//
+//	func main() {
+//		config.RegisterSchema("app", &AppConfig{})
+//		os.Exit(config.RunLint(os.Args[1:], os.Stdout, os.Stderr))
+//	}
+func RunLint(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("config-lint", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	schema := fs.String("schema", "", "registered schema to check files against (optional if only one is registered)")
+	keyFile := fs.String("key-file", "", "key file for decrypting ENC[...] values")
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "usage: config-lint [-schema name] [-key-file path] file...")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return 2
+	}
+
+	name := *schema
+	if name == "" {
+		names := schemaNames()
+		if len(names) != 1 {
+			fmt.Fprintf(stderr, "config-lint: -schema is required, registered: %s\n", strings.Join(names, ", "))
+			return 2
+		}
+		name = names[0]
+	}
+
+	var opts []Option
+	if *keyFile != "" {
+		opts = append(opts, WithKeyFile(*keyFile))
+	}
+
+	code := 0
+	var rows [][]interface{}
+	for _, file := range fs.Args() {
+		err := Lint(name, file, opts...)
+		if err == nil {
+			rows = append(rows, []interface{}{file, "OK", "", "", ""})
+			continue
+		}
+		code = 1
+		for _, e := range lintProblems(err) {
+			rows = append(rows, lintRow(file, e))
+		}
+	}
+	utils.FprintTable(stdout, []interface{}{"File", "Status", "Path", "Position", "Error"}, rows)
+	return code
+}
+
+// lintProblems returns the individual problems joined in err, or err itself.
+func lintProblems(err error) []error {
+	for e := err; e != nil; e = errors.Unwrap(e) {
+		if _, ok := e.(interface{ Unwrap() []error }); ok {
+			return flattenErrors(e)
+		}
+	}
+	return []error{err}
+}
+
+// lintRow formats one problem, splitting out the path and position of a FieldError.
+func lintRow(file string, err error) []interface{} {
+	var fe *FieldError
+	if !errors.As(err, &fe) {
+		return []interface{}{file, "FAIL", "", "", err.Error()}
+	}
+	pos := ""
+	if fe.Line > 0 {
+		pos = fmt.Sprintf("%d:%d", fe.Line, fe.Column)
+	}
+	detail := fe.Err.Error()
+	if fe.Value != "" {
+		detail = fmt.Sprintf("invalid value %q: %s", fe.Value, detail)
+	}
+	return []interface{}{file, "FAIL", fe.Path, pos, detail}
+}
+
+func schemaNames() []string {
+	schemasMu.RLock()
+	defer schemasMu.RUnlock()
+
+	names := make([]string, 0, len(schemas))
+	for name := range schemas {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,61 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testLintConfig struct {
+	Name string `yaml:"name"`
+	Port int    `yaml:"port"`
+}
+
+func (c *testLintConfig) Validate() error {
+	if c.Port <= 0 {
+		return errors.New("port must be positive")
+	}
+	return nil
+}
+
+func TestLint(t *testing.T) {
+	RegisterSchema("lint-test", &testLintConfig{})
+	dir := t.TempDir()
+	good := writeFile(t, dir, "good.yaml", "version: 1\nname: svc\nport: 80\n")
+	unknown := writeFile(t, dir, "unknown.yaml", "name: svc\nport: 80\nprot: 81\n")
+	invalid := writeFile(t, dir, "invalid.yaml", "name: svc\n")
+
+	assert.NoError(t, Lint("lint-test", good))
+	assert.ErrorIs(t, Lint("lint-test", unknown), ErrUnknownKey)
+	assert.ErrorContains(t, Lint("lint-test", invalid), "port must be positive")
+	assert.ErrorContains(t, Lint("missing", good), "not registered")
+
+	var out bytes.Buffer
+	assert.Equal(t, 1, RunLint([]string{"-schema", "lint-test", good, unknown}, &out, io.Discard))
+	assert.Contains(t, out.String(), "OK")
+	assert.Contains(t, out.String(), "FAIL")
+	assert.Contains(t, out.String(), "unknown.yaml")
+	assert.Contains(t, out.String(), "3:1")
+
+	assert.Equal(t, 0, RunLint([]string{"-schema", "lint-test", good}, io.Discard, io.Discard))
+
+	var stderr bytes.Buffer
+	assert.Equal(t, 2, RunLint(nil, io.Discard, &stderr))
+	assert.Contains(t, stderr.String(), "usage: config-lint")
+}
+
+func TestLoad_Validate(t *testing.T) {
+	var cfg struct {
+		Server testLintConfig `yaml:"server"`
+	}
+	src := "server:\n  port: 0\n"
+	err := NewLoader(&cfg, WithReader(strings.NewReader(src), FormatYAML), WithValidation()).Load()
+	assert.ErrorContains(t, err, "server: port must be positive")
+
+	// Validation is opt-in.
+	assert.NoError(t, NewLoader(&cfg, WithReader(strings.NewReader(src), FormatYAML)).Load())
+}
@@ -0,0 +1,93 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+)
+
+// versionKey is the top-level key holding the format version of a config document.
+const versionKey = "version"
+
+// Migration upgrades a raw config document in place from one version to the next.
+// Numbers in JSON documents are json.Number values.
+type Migration func(doc map[string]interface{}) error
+
+// WithMigration registers fn to upgrade documents from version from to from+1.
+//
+// Before decoding, every document whose version is older than the latest registered
+// target runs through the migrations in order (v1→v2→v3...) and its version key is set
+// to the latest. The WithFile and WithReader documents are treated as version 1 without
+// a version key; included and WithDir files are only migrated if they have one.
+func WithMigration(from int, fn Migration) Option {
+	return func(l *Loader) {
+		if l.migrations == nil {
+			l.migrations = make(map[int]Migration)
+		}
+		l.migrations[from] = fn
+	}
+}
+
+// migrateDocument runs the migrations needed to bring doc to the latest version
+// and reports whether doc was changed.
+func migrateDocument(doc map[string]interface{}, migrations map[int]Migration) (bool, error) {
+	if len(migrations) == 0 || doc == nil {
+		return false, nil
+	}
+
+	latest := latestVersion(migrations)
+	version, err := documentVersion(doc)
+	if err != nil {
+		return false, err
+	}
+	if version > latest {
+		return false, fmt.Errorf("config version %d is newer than the latest supported version %d", version, latest)
+	}
+	if version == latest {
+		return false, nil
+	}
+
+	for v := version; v < latest; v++ {
+		fn, ok := migrations[v]
+		if !ok {
+			return false, fmt.Errorf("no migration registered from config version %d", v)
+		}
+		if err := fn(doc); err != nil {
+			return false, fmt.Errorf("migration from config version %d failed: %w", v, err)
+		}
+	}
+	doc[versionKey] = latest
+	return true, nil
+}
+
+// latestVersion returns the version the migrations upgrade to, or 0 without migrations.
+func latestVersion(migrations map[int]Migration) int {
+	latest := 0
+	for from := range migrations {
+		latest = max(latest, from+1)
+	}
+	return latest
+}
+
+// documentVersion returns the version of doc, defaulting to 1.
+func documentVersion(doc map[string]interface{}) (int, error) {
+	switch v := doc[versionKey].(type) {
+	case nil:
+		return 1, nil
+	case int:
+		return v, nil
+	case float64:
+		if v == float64(int(v)) {
+			return int(v), nil
+		}
+	case json.Number:
+		if i, err := strconv.Atoi(v.String()); err == nil {
+			return i, nil
+		}
+	case string:
+		if i, err := strconv.Atoi(v); err == nil {
+			return i, nil
+		}
+	}
+	return 0, fmt.Errorf("invalid config version %v", doc[versionKey])
+}
@@ -0,0 +1,87 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+)
+
+// Sources reported by Provenance.
+const (
+	SourceDefault    = "default"
+	SourceFilePrefix = "file:"
+	SourceEnvPrefix  = "env:"
+)
+
+// Provenance returns the source of each field set in the current version, keyed by
+// dotted key path (e.g. "database.port"). Values are SourceDefault, "file:<path>" or "env:<VAR>".
+// Fields that kept their zero value are omitted.
+func (l *Loader) Provenance() map[string]string {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	out := make(map[string]string)
+	if len(l.history) == 0 {
+		return out
+	}
+	for k, v := range l.history[len(l.history)-1].result.sources {
+		out[k] = v
+	}
+	return out
+}
+
+// markFileSources records source for every field of t present in doc, a document of
+// the given format, under the field's key path.
+func markFileSources(t reflect.Type, doc map[string]interface{}, prefix, source string, sources map[string]string, format Format) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+
+		key := documentKey(f, format)
+		if key == "" {
+			continue
+		}
+		val, ok := lookupKey(doc, key, format == FormatJSON)
+		if !ok {
+			continue
+		}
+		path := joinPath(prefix, keyName(f))
+
+		ft := f.Type
+		if ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+		if sub, isMap := val.(map[string]interface{}); isMap && ft.Kind() == reflect.Struct {
+			markFileSources(ft, sub, path, source, sources, format)
+			continue
+		}
+		sources[path] = source
+	}
+}
+
+// lookupKey finds key in doc. With foldCase, it falls back to a case-insensitive match
+// as encoding/json does; YAML keys are case-sensitive.
+func lookupKey(doc map[string]interface{}, key string, foldCase bool) (interface{}, bool) {
+	k, ok := findKey(doc, key, foldCase)
+	if !ok {
+		return nil, false
+	}
+	return doc[k], true
+}
+
+// findKey returns the key in doc matching key exactly or else, with foldCase, case-insensitively.
+func findKey(doc map[string]interface{}, key string, foldCase bool) (string, bool) {
+	if _, ok := doc[key]; ok {
+		return key, true
+	}
+	if !foldCase {
+		return "", false
+	}
+	for k := range doc {
+		if strings.EqualFold(k, key) {
+			return k, true
+		}
+	}
+	return "", false
+}
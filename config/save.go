@@ -0,0 +1,410 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// errPlaintextSecret is reported by Save for secret values it would have to write unencrypted.
+var errPlaintextSecret = errors.New("refusing to write a secret value in plaintext without an encryption key")
+
+// Save writes the struct passed to NewLoader back to the configured file, in the
+// file's format, replacing it atomically.
+//
+// Only keys read from the file itself and values changed in the struct since the last
+// Load are written, so values from defaults, env vars, readers and other files stay
+// where they came from. The existing document is updated, so its other keys (such as
+// include) are kept; for YAML, comments and key order are preserved where possible.
+//
+// Fields tagged `secret:"true"` and values that were ENC[...] in the file are written
+// encrypted: values still matching the file keep their ciphertext and others are
+// encrypted with the configured key. Without a key, Save fails rather than write a new
+// secret in plaintext. With migrations registered, the version key is set to the latest;
+// keys moved by migrations or deprecated aliases are removed from their old paths.
+func (l *Loader) Save() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.configFile == "" {
+		return errors.New("no config file configured")
+	}
+	if l.fsys != nil {
+		return errors.New("cannot save to a config file loaded from an fs.FS")
+	}
+	if l.loadedCfg == nil {
+		return errors.New("config must be loaded before it is saved")
+	}
+	format, err := formatOf(l.configFile)
+	if err != nil {
+		return err
+	}
+
+	old, err := os.ReadFile(l.configFile)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	var oldDoc map[string]interface{}
+	var stale []string
+	if len(old) > 0 {
+		// An unparsable file is rewritten from the struct
+		oldDoc, _ = parseDocument(old, format)
+		stale = l.stalePaths(old, format)
+		for _, path := range stale {
+			deletePath(oldDoc, path, format == FormatJSON)
+		}
+	}
+
+	cfg, err := l.encryptSecrets(oldDoc, format)
+	if err != nil {
+		return err
+	}
+
+	enc, err := toDocument(cfg, format)
+	if err != nil {
+		return fmt.Errorf("failed to encode config: %w", err)
+	}
+	plain, err := toDocument(l.cfg, format)
+	if err != nil {
+		return fmt.Errorf("failed to encode config: %w", err)
+	}
+	loaded, err := toDocument(l.loadedCfg, format)
+	if err != nil {
+		return fmt.Errorf("failed to encode config: %w", err)
+	}
+	doc := l.savedEntries(enc, plain, loaded, "")
+	if latest := latestVersion(l.migrations); latest > 0 {
+		doc[versionKey] = latest
+	}
+
+	var data []byte
+	switch format {
+	case FormatJSON:
+		data, err = json.MarshalIndent(mergeDocument(oldDoc, doc), "", "  ")
+		data = append(data, '\n')
+	default:
+		data, err = encodeYAMLPreserving(old, doc, stale)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to encode config: %w", err)
+	}
+
+	return writeFileAtomic(l.configFile, data)
+}
+
+// stalePaths returns the paths in the file document that its migrations and deprecated
+// aliases move or remove on load, so Save drops them instead of writing them back.
+func (l *Loader) stalePaths(data []byte, format Format) []string {
+	doc, err := parseDocument(data, format)
+	if err != nil {
+		return nil
+	}
+	upgraded, _ := parseDocument(data, format)
+	if _, err := migrateDocument(upgraded, l.migrations); err != nil {
+		return nil
+	}
+	foldCase := format == FormatJSON
+	applyAliases(reflect.TypeOf(l.cfg).Elem(), upgraded, "", format)
+
+	var stale []string
+	collectStalePaths(doc, upgraded, "", foldCase, &stale)
+	return stale
+}
+
+// collectStalePaths appends the paths of doc missing from upgraded to out. A document
+// left empty by the upgrade is stale as a whole.
+func collectStalePaths(doc, upgraded map[string]interface{}, prefix string, foldCase bool, out *[]string) {
+	for k, v := range doc {
+		path := joinPath(prefix, k)
+		uv, ok := lookupKey(upgraded, k, foldCase)
+		if !ok {
+			*out = append(*out, path)
+			continue
+		}
+		sub, isMap := v.(map[string]interface{})
+		usub, uIsMap := uv.(map[string]interface{})
+		if !isMap || !uIsMap {
+			continue
+		}
+		if len(usub) == 0 && len(sub) > 0 {
+			*out = append(*out, path)
+			continue
+		}
+		collectStalePaths(sub, usub, path, foldCase, out)
+	}
+}
+
+// fromFile reports whether the value at path was read from the config file itself.
+// The caller must hold l.mu.
+func (l *Loader) fromFile(path string) bool {
+	return sourceOf(l.loadedResult.sources, path) == SourceFilePrefix+l.configFile
+}
+
+// savedEntries returns the entries of enc that Save writes: those read from the file and
+// those whose value in plain differs from loaded. All three documents encode the same type,
+// enc with secrets encrypted. The caller must hold l.mu.
+func (l *Loader) savedEntries(enc, plain, loaded map[string]interface{}, prefix string) map[string]interface{} {
+	out := make(map[string]interface{})
+	for k, v := range enc {
+		path := joinPath(prefix, k)
+		sub, isMap := v.(map[string]interface{})
+		plainSub, plainIsMap := plain[k].(map[string]interface{})
+		if isMap && plainIsMap {
+			loadedSub, _ := loaded[k].(map[string]interface{})
+			if entries := l.savedEntries(sub, plainSub, loadedSub, path); len(entries) > 0 || l.fromFile(path) {
+				out[k] = entries
+			}
+			continue
+		}
+		if l.fromFile(path) || !reflect.DeepEqual(plain[k], loaded[k]) {
+			out[k] = v
+		}
+	}
+	return out
+}
+
+// encryptSecrets returns a copy of the config where the secret and previously encrypted
+// values Save writes are in ENC[...] form: the ciphertext from the old file if it still
+// decrypts to the value, else a new one. Values already in the old file as plaintext
+// stay as they are. The caller must hold l.mu.
+func (l *Loader) encryptSecrets(oldDoc map[string]interface{}, format Format) (interface{}, error) {
+	cfg := cloneConfig(l.cfg)
+	secrets := make(map[string]bool)
+	secretPaths(reflect.ValueOf(cfg).Elem(), "", secrets)
+	for path := range l.loadedResult.encrypted {
+		secrets[path] = true
+	}
+	if len(secrets) == 0 {
+		return cfg, nil
+	}
+
+	loaded := make(map[string]string)
+	_ = rewriteStrings(reflect.ValueOf(cloneConfig(l.loadedCfg)).Elem(), "", func(path, s string) (string, error) {
+		loaded[path] = s
+		return s, nil
+	})
+
+	key, keyErr := l.decryptionKey()
+	var errs []error
+	err := rewriteStrings(reflect.ValueOf(cfg).Elem(), "", func(path, s string) (string, error) {
+		if !secrets[path] || s == "" || IsEncrypted(s) {
+			return s, nil
+		}
+		if !l.fromFile(path) && loaded[path] == s {
+			// Not written
+			return s, nil
+		}
+
+		old, _ := getPath(oldDoc, path, format == FormatJSON)
+		if old == s {
+			return s, nil
+		}
+		if enc, ok := old.(string); ok && IsEncrypted(enc) && key != nil {
+			if plaintext, err := Decrypt(key, enc); err == nil && plaintext == s {
+				return enc, nil
+			}
+		}
+
+		switch {
+		case keyErr != nil:
+			errs = append(errs, &FieldError{Path: path, Err: fmt.Errorf("failed to load encryption key: %w", keyErr)})
+		case key == nil:
+			errs = append(errs, &FieldError{Path: path, Err: errPlaintextSecret})
+		default:
+			return Encrypt(key, s)
+		}
+		return s, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return cfg, errors.Join(errs...)
+}
+
+// secretPaths records the paths of string fields tagged `secret:"true"` in v.
+func secretPaths(v reflect.Value, prefix string, out map[string]bool) {
+	t := v.Type()
+	for i := 0; i < v.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		path := joinPath(prefix, keyName(f))
+		fv := v.Field(i)
+		if fv.Kind() == reflect.Ptr && !fv.IsNil() {
+			fv = fv.Elem()
+		}
+		switch {
+		case f.Tag.Get("secret") == "true" && fv.Kind() == reflect.String:
+			out[path] = true
+		case fv.Kind() == reflect.Struct:
+			secretPaths(fv, path, out)
+		}
+	}
+}
+
+// toDocument encodes cfg in format and parses it back into a generic document.
+func toDocument(cfg interface{}, format Format) (map[string]interface{}, error) {
+	var data []byte
+	var err error
+	if format == FormatJSON {
+		data, err = json.Marshal(cfg)
+	} else {
+		data, err = yaml.Marshal(cfg)
+	}
+	if err != nil {
+		return nil, err
+	}
+	doc, err := parseDocument(data, format)
+	if doc == nil {
+		doc = make(map[string]interface{})
+	}
+	return doc, err
+}
+
+// mergeDocument sets the entries of src in dst, merging nested documents, and returns dst.
+// Keys match case-insensitively, as the JSON decoder does.
+func mergeDocument(dst, src map[string]interface{}) map[string]interface{} {
+	if dst == nil {
+		return src
+	}
+	for k, v := range src {
+		if found, ok := findKey(dst, k, true); ok {
+			k = found
+		}
+		sub, isMap := v.(map[string]interface{})
+		dstSub, dstIsMap := dst[k].(map[string]interface{})
+		if isMap && dstIsMap {
+			dst[k] = mergeDocument(dstSub, sub)
+			continue
+		}
+		dst[k] = v
+	}
+	return dst
+}
+
+// encodeYAMLPreserving encodes doc as YAML, merged into the old document if it parses
+// after removing the stale paths from it.
+func encodeYAMLPreserving(old []byte, doc map[string]interface{}, stale []string) ([]byte, error) {
+	var src yaml.Node
+	if err := src.Encode(doc); err != nil {
+		return nil, err
+	}
+
+	out := &src
+	var dst yaml.Node
+	if err := yaml.Unmarshal(old, &dst); err == nil && len(dst.Content) == 1 && dst.Content[0].Kind == yaml.MappingNode {
+		for _, path := range stale {
+			deleteYAMLPath(dst.Content[0], path)
+		}
+		mergeYAMLNode(dst.Content[0], &src)
+		out = &dst
+	}
+
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(out); err != nil {
+		return nil, err
+	}
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// mergeYAMLNode updates dst with the values of src, keeping dst's comments, key order,
+// and keys missing from src. Keys only in src are appended.
+func mergeYAMLNode(dst, src *yaml.Node) {
+	if dst.Kind == yaml.MappingNode && src.Kind == yaml.MappingNode {
+		for i := 0; i+1 < len(src.Content); i += 2 {
+			key, val := src.Content[i], src.Content[i+1]
+			if j := mappingIndex(dst, key.Value); j >= 0 {
+				mergeYAMLNode(dst.Content[j+1], val)
+			} else {
+				dst.Content = append(dst.Content, key, val)
+			}
+		}
+		return
+	}
+
+	// Keep unchanged scalars as written, e.g. with their quoting style
+	if dst.Kind == yaml.ScalarNode && src.Kind == yaml.ScalarNode && dst.Value == src.Value {
+		return
+	}
+
+	head, line, foot := dst.HeadComment, dst.LineComment, dst.FootComment
+	*dst = *src
+	dst.HeadComment, dst.LineComment, dst.FootComment = head, line, foot
+}
+
+// deleteYAMLPath removes the entry at a dotted key path from a mapping node.
+func deleteYAMLPath(m *yaml.Node, path string) {
+	keys := strings.Split(path, ".")
+	for i, k := range keys {
+		if m.Kind != yaml.MappingNode {
+			return
+		}
+		j := mappingIndex(m, k)
+		if j < 0 {
+			return
+		}
+		if i == len(keys)-1 {
+			m.Content = append(m.Content[:j], m.Content[j+2:]...)
+			return
+		}
+		m = m.Content[j+1]
+	}
+}
+
+// mappingIndex returns the index of key in a mapping node's content, or -1.
+func mappingIndex(m *yaml.Node, key string) int {
+	for i := 0; i+1 < len(m.Content); i += 2 {
+		if m.Content[i].Value == key {
+			return i
+		}
+	}
+	return -1
+}
+
+// writeFileAtomic writes data to a temporary file next to path and renames it over path,
+// keeping the existing file mode.
+func writeFileAtomic(path string, data []byte) (err error) {
+	mode := os.FileMode(0644)
+	if fi, statErr := os.Stat(path); statErr == nil {
+		mode = fi.Mode().Perm()
+	}
+
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = os.Remove(f.Name())
+		}
+	}()
+
+	if _, err = f.Write(data); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err = f.Sync(); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err = f.Close(); err != nil {
+		return err
+	}
+	if err = os.Chmod(f.Name(), mode); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
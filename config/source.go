@@ -0,0 +1,115 @@
+package config
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Format is the encoding of a config source.
+type Format string
+
+const (
+	FormatYAML Format = "yaml"
+	FormatJSON Format = "json"
+)
+
+// SourceReader is reported by Provenance for fields set from WithReader.
+const SourceReader = "reader"
+
+// WithFS resolves WithFile, WithDir and include paths inside fsys instead of the OS
+// file system, e.g. to ship default configs with embed.FS.
+func WithFS(fsys fs.FS) Option {
+	return func(l *Loader) {
+		l.fsys = fsys
+	}
+}
+
+// WithReader loads config from r in the given format before the file and directory,
+// so those override it. r is read once, on the first load, and reused by refreshes.
+// As a reader has no location to resolve them against, include directives in it are
+// errors; use WithFile or WithDir to load further files.
+func WithReader(r io.Reader, format Format) Option {
+	return func(l *Loader) {
+		l.reader = r
+		l.readerFormat = format
+	}
+}
+
+// readerData returns the content of the WithReader source, reading it on first use.
+func (l *Loader) readerData() ([]byte, error) {
+	l.readerOnce.Do(func() {
+		l.readerBytes, l.readerErr = io.ReadAll(l.reader)
+	})
+	return l.readerBytes, l.readerErr
+}
+
+// formatOf derives the format of a config file from its extension.
+func formatOf(name string) (Format, error) {
+	ext := strings.ToLower(filepath.Ext(name))
+	switch ext {
+	case ".json":
+		return FormatJSON, nil
+	case ".yaml", ".yml":
+		return FormatYAML, nil
+	default:
+		return "", fmt.Errorf("unsupported file extension: %s", ext)
+	}
+}
+
+// sourceFS abstracts file access so config files can come from the OS or any fs.FS.
+type sourceFS interface {
+	ReadFile(name string) ([]byte, error)
+	ReadDir(name string) ([]fs.DirEntry, error)
+	Glob(pattern string) ([]string, error)
+
+	// Join joins a directory and a file name.
+	Join(dir, name string) string
+
+	// Resolve joins a possibly relative name to the directory of the including file.
+	Resolve(from, name string) string
+
+	// ID returns a canonical name used to detect include cycles.
+	ID(name string) (string, error)
+}
+
+type osFS struct{}
+
+func (osFS) ReadFile(name string) ([]byte, error)       { return os.ReadFile(name) }
+func (osFS) ReadDir(name string) ([]fs.DirEntry, error) { return os.ReadDir(name) }
+func (osFS) Glob(pattern string) ([]string, error)      { return filepath.Glob(pattern) }
+func (osFS) ID(name string) (string, error)             { return filepath.Abs(name) }
+func (osFS) Join(dir, name string) string               { return filepath.Join(dir, name) }
+
+func (osFS) Resolve(from, name string) string {
+	if filepath.IsAbs(name) {
+		return name
+	}
+	return filepath.Join(filepath.Dir(from), name)
+}
+
+type ioFS struct {
+	fsys fs.FS
+}
+
+func (f ioFS) ReadFile(name string) ([]byte, error)       { return fs.ReadFile(f.fsys, name) }
+func (f ioFS) ReadDir(name string) ([]fs.DirEntry, error) { return fs.ReadDir(f.fsys, name) }
+func (f ioFS) Glob(pattern string) ([]string, error)      { return fs.Glob(f.fsys, pattern) }
+func (f ioFS) ID(name string) (string, error)             { return path.Clean(name), nil }
+func (f ioFS) Join(dir, name string) string               { return path.Join(dir, name) }
+
+func (f ioFS) Resolve(from, name string) string {
+	return path.Join(path.Dir(from), name)
+}
+
+// sourceFS returns the file system config files are read from.
+func (l *Loader) sourceFS() sourceFS {
+	if l.fsys != nil {
+		return ioFS{fsys: l.fsys}
+	}
+	return osFS{}
+}
@@ -0,0 +1,259 @@
+package config
+
+import (
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ByteSize is a size in bytes that parses units such as "512MiB", "1.5GB" or "64k".
+// Decimal units (KB, MB, GB, TB, PB) are powers of 1000, binary units (KiB, MiB, ...)
+// and single letters (K, M, G, T, P) are powers of 1024. Plain numbers are bytes;
+// fractional values must come to a whole number of bytes.
+type ByteSize uint64
+
+// Byte size units.
+const (
+	Byte ByteSize = 1
+
+	KB ByteSize = 1000 * Byte
+	MB ByteSize = 1000 * KB
+	GB ByteSize = 1000 * MB
+	TB ByteSize = 1000 * GB
+	PB ByteSize = 1000 * TB
+
+	KiB ByteSize = 1024 * Byte
+	MiB ByteSize = 1024 * KiB
+	GiB ByteSize = 1024 * MiB
+	TiB ByteSize = 1024 * GiB
+	PiB ByteSize = 1024 * TiB
+)
+
+var byteUnits = map[string]ByteSize{
+	"": Byte, "b": Byte,
+	"kb": KB, "mb": MB, "gb": GB, "tb": TB, "pb": PB,
+	"kib": KiB, "mib": MiB, "gib": GiB, "tib": TiB, "pib": PiB,
+	"k": KiB, "m": MiB, "g": GiB, "t": TiB, "p": PiB,
+}
+
+// byteFormats lists the units String tries, largest first.
+var byteFormats = []struct {
+	unit ByteSize
+	name string
+}{
+	{PiB, "PiB"}, {TiB, "TiB"}, {GiB, "GiB"}, {MiB, "MiB"}, {KiB, "KiB"},
+	{PB, "PB"}, {TB, "TB"}, {GB, "GB"}, {MB, "MB"}, {KB, "KB"},
+}
+
+var unitPattern = regexp.MustCompile(`^([0-9]*\.?[0-9]+)\s*([a-zA-Z]*)$`)
+
+// ParseByteSize parses a size such as "512MiB" or "1.5GB".
+func ParseByteSize(s string) (ByteSize, error) {
+	m := unitPattern.FindStringSubmatch(strings.TrimSpace(s))
+	if m == nil {
+		return 0, fmt.Errorf("invalid byte size %q", s)
+	}
+	unit, ok := byteUnits[strings.ToLower(m[2])]
+	if !ok {
+		return 0, fmt.Errorf("invalid byte size %q: unknown unit %q", s, m[2])
+	}
+	// Parse exactly, as float64 cannot tell 1.1MB from 1100000.0000000002 bytes.
+	n, ok := new(big.Rat).SetString(m[1])
+	if !ok {
+		return 0, fmt.Errorf("invalid byte size %q", s)
+	}
+	n.Mul(n, new(big.Rat).SetUint64(uint64(unit)))
+	if !n.IsInt() {
+		return 0, fmt.Errorf("invalid byte size %q: not a whole number of bytes", s)
+	}
+	if !n.Num().IsUint64() {
+		return 0, fmt.Errorf("invalid byte size %q: out of range", s)
+	}
+	return ByteSize(n.Num().Uint64()), nil
+}
+
+// String formats the size with the largest unit that represents it exactly, e.g. "512MiB".
+func (b ByteSize) String() string {
+	for _, f := range byteFormats {
+		if b >= f.unit && b%f.unit == 0 {
+			return strconv.FormatUint(uint64(b/f.unit), 10) + f.name
+		}
+	}
+	return strconv.FormatUint(uint64(b), 10) + "B"
+}
+
+// Bytes returns the size as an int64 for APIs that take byte counts.
+func (b ByteSize) Bytes() int64 {
+	return int64(b)
+}
+
+func (b ByteSize) MarshalText() ([]byte, error) {
+	return []byte(b.String()), nil
+}
+
+func (b *ByteSize) UnmarshalText(text []byte) error {
+	v, err := ParseByteSize(string(text))
+	if err != nil {
+		return err
+	}
+	*b = v
+	return nil
+}
+
+func (b ByteSize) MarshalJSON() ([]byte, error) {
+	return json.Marshal(b.String())
+}
+
+func (b *ByteSize) UnmarshalJSON(data []byte) error {
+	return unmarshalJSONText(data, b)
+}
+
+func (b ByteSize) MarshalYAML() (interface{}, error) {
+	return b.String(), nil
+}
+
+func (b *ByteSize) UnmarshalYAML(value *yaml.Node) error {
+	return b.UnmarshalText([]byte(value.Value))
+}
+
+// Percent is a percentage that parses "90%" or "90" as 90.
+type Percent float64
+
+// ParsePercent parses a percentage such as "90%" or "12.5".
+func ParsePercent(s string) (Percent, error) {
+	f, err := strconv.ParseFloat(strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(s), "%")), 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid percentage %q", s)
+	}
+	return Percent(f), nil
+}
+
+// Fraction returns the percentage as a fraction, e.g. 0.9 for 90%.
+func (p Percent) Fraction() float64 {
+	return float64(p) / 100
+}
+
+// String formats the percentage, e.g. "90%".
+func (p Percent) String() string {
+	return strconv.FormatFloat(float64(p), 'f', -1, 64) + "%"
+}
+
+func (p Percent) MarshalText() ([]byte, error) {
+	return []byte(p.String()), nil
+}
+
+func (p *Percent) UnmarshalText(text []byte) error {
+	v, err := ParsePercent(string(text))
+	if err != nil {
+		return err
+	}
+	*p = v
+	return nil
+}
+
+func (p Percent) MarshalJSON() ([]byte, error) {
+	return json.Marshal(p.String())
+}
+
+func (p *Percent) UnmarshalJSON(data []byte) error {
+	return unmarshalJSONText(data, p)
+}
+
+func (p Percent) MarshalYAML() (interface{}, error) {
+	return p.String(), nil
+}
+
+func (p *Percent) UnmarshalYAML(value *yaml.Node) error {
+	return p.UnmarshalText([]byte(value.Value))
+}
+
+// Duration is a time.Duration that also accepts days ("1d", "1d12h") and
+// encodes as a duration string in YAML and JSON.
+type Duration time.Duration
+
+var dayPattern = regexp.MustCompile(`([0-9]*\.?[0-9]+)d`)
+
+// ParseDuration parses a duration like time.ParseDuration, with "d" for 24 hours.
+func ParseDuration(s string) (time.Duration, error) {
+	s = strings.TrimSpace(s)
+	var convErr error
+	expanded := dayPattern.ReplaceAllStringFunc(s, func(m string) string {
+		days, err := strconv.ParseFloat(strings.TrimSuffix(m, "d"), 64)
+		if err != nil {
+			convErr = err
+			return m
+		}
+		return strconv.FormatFloat(days*24, 'f', -1, 64) + "h"
+	})
+	if convErr != nil {
+		return 0, fmt.Errorf("invalid duration %q: %w", s, convErr)
+	}
+	d, err := time.ParseDuration(expanded)
+	if err != nil {
+		return 0, fmt.Errorf("invalid duration %q", s)
+	}
+	return d, nil
+}
+
+// Std returns the value as a time.Duration.
+func (d Duration) Std() time.Duration {
+	return time.Duration(d)
+}
+
+func (d Duration) String() string {
+	return time.Duration(d).String()
+}
+
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(d.String()), nil
+}
+
+// UnmarshalText accepts a duration string or a number of nanoseconds.
+func (d *Duration) UnmarshalText(text []byte) error {
+	if n, err := strconv.ParseInt(string(text), 10, 64); err == nil {
+		*d = Duration(n)
+		return nil
+	}
+	v, err := ParseDuration(string(text))
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	return unmarshalJSONText(data, d)
+}
+
+func (d Duration) MarshalYAML() (interface{}, error) {
+	return d.String(), nil
+}
+
+func (d *Duration) UnmarshalYAML(value *yaml.Node) error {
+	return d.UnmarshalText([]byte(value.Value))
+}
+
+// unmarshalJSONText decodes a JSON string or number through UnmarshalText.
+func unmarshalJSONText(data []byte, v encoding.TextUnmarshaler) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		return v.UnmarshalText([]byte(s))
+	}
+	var n json.Number
+	if err := json.Unmarshal(data, &n); err != nil {
+		return fmt.Errorf("expected a string or number, got %s", data)
+	}
+	return v.UnmarshalText([]byte(n.String()))
+}
@@ -0,0 +1,129 @@
+package config
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v3"
+)
+
+func TestParseByteSize(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    ByteSize
+		wantErr bool
+	}{
+		{"1024", 1024, false},
+		{"512MiB", 512 * MiB, false},
+		{"1.5GB", 1500 * MB, false},
+		{"64k", 64 * KiB, false},
+		{"10 KB", 10 * KB, false},
+		{"1.5KiB", 1536, false},
+		{"1.1MB", 1100 * KB, false},
+		{"1.5", 0, true},
+		{"0.5KB", 500, false},
+		{"0.0001KB", 0, true},
+		{"18446744073709551615", 1<<64 - 1, false},
+		{"18446744073709551616", 0, true},
+		{"16PiB", 16 * PiB, false},
+		{"16384PiB", 0, true},
+		{"12XB", 0, true},
+		{"big", 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := ParseByteSize(tt.in)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+
+	assert.Equal(t, "512MiB", (512 * MiB).String())
+	assert.Equal(t, "1500MB", (1500 * MB).String())
+	assert.Equal(t, "7B", ByteSize(7).String())
+}
+
+func TestParseDuration(t *testing.T) {
+	d, err := ParseDuration("1d12h")
+	assert.NoError(t, err)
+	assert.Equal(t, 36*time.Hour, d)
+
+	d, err = ParseDuration("0.5d")
+	assert.NoError(t, err)
+	assert.Equal(t, 12*time.Hour, d)
+
+	d, err = ParseDuration("90s")
+	assert.NoError(t, err)
+	assert.Equal(t, 90*time.Second, d)
+
+	_, err = ParseDuration("soon")
+	assert.Error(t, err)
+}
+
+func TestParsePercent(t *testing.T) {
+	p, err := ParsePercent("90%")
+	assert.NoError(t, err)
+	assert.Equal(t, Percent(90), p)
+	assert.Equal(t, 0.9, p.Fraction())
+	assert.Equal(t, "12.5%", Percent(12.5).String())
+
+	_, err = ParsePercent("most")
+	assert.Error(t, err)
+}
+
+type testUnitsConfig struct {
+	CacheSize ByteSize      `yaml:"cache_size" json:"cache_size" default:"64MiB" env:"TEST_CACHE_SIZE"`
+	Threshold Percent       `yaml:"threshold" json:"threshold" default:"80%"`
+	Retention Duration      `yaml:"retention" json:"retention" default:"7d"`
+	Timeout   time.Duration `yaml:"timeout" json:"timeout" default:"1d"`
+}
+
+func TestLoad_Units(t *testing.T) {
+	var cfg testUnitsConfig
+	assert.NoError(t, NewLoader(&cfg).Load())
+	assert.Equal(t, 64*MiB, cfg.CacheSize)
+	assert.Equal(t, Percent(80), cfg.Threshold)
+	assert.Equal(t, 7*24*time.Hour, cfg.Retention.Std())
+	assert.Equal(t, 24*time.Hour, cfg.Timeout)
+
+	t.Setenv("TEST_CACHE_SIZE", "1.5GB")
+	cfg = testUnitsConfig{}
+	r := strings.NewReader("threshold: 90%\nretention: 1d12h\n")
+	assert.NoError(t, NewLoader(&cfg, WithReader(r, FormatYAML)).Load())
+	assert.Equal(t, 1500*MB, cfg.CacheSize)
+	assert.Equal(t, Percent(90), cfg.Threshold)
+	assert.Equal(t, 36*time.Hour, cfg.Retention.Std())
+
+	t.Setenv("TEST_CACHE_SIZE", "")
+	cfg = testUnitsConfig{}
+	r = strings.NewReader(`{"cache_size": 2048, "threshold": 12.5, "retention": "2d"}`)
+	assert.NoError(t, NewLoader(&cfg, WithReader(r, FormatJSON)).Load())
+	assert.Equal(t, 2*KiB, cfg.CacheSize)
+	assert.Equal(t, Percent(12.5), cfg.Threshold)
+	assert.Equal(t, 48*time.Hour, cfg.Retention.Std())
+}
+
+func TestUnits_RoundTrip(t *testing.T) {
+	in := testUnitsConfig{CacheSize: 512 * MiB, Threshold: 90, Retention: Duration(36 * time.Hour)}
+
+	data, err := json.Marshal(in)
+	assert.NoError(t, err)
+	assert.Contains(t, string(data), `"cache_size":"512MiB"`)
+	var fromJSON testUnitsConfig
+	assert.NoError(t, json.Unmarshal(data, &fromJSON))
+	assert.Equal(t, in, fromJSON)
+
+	data, err = yaml.Marshal(in)
+	assert.NoError(t, err)
+	assert.Contains(t, string(data), "threshold: 90%")
+	var fromYAML testUnitsConfig
+	assert.NoError(t, yaml.Unmarshal(data, &fromYAML))
+	assert.Equal(t, in, fromYAML)
+}
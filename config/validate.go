@@ -0,0 +1,84 @@
+package config
+
+import (
+	"errors"
+	"reflect"
+)
+
+// Validator is implemented by config structs (or nested structs) that check their own values.
+// With WithValidation, Validate runs after all sources are applied; a failure aborts Load and refresh.
+type Validator interface {
+	Validate() error
+}
+
+// WithValidation calls Validate on every load for the config struct and nested structs
+// implementing Validator. Lint always enables it.
+func WithValidation() Option {
+	return func(l *Loader) {
+		l.validate = true
+	}
+}
+
+// WithStrict makes keys in config files that do not map to a struct field an error.
+// The reserved include and version keys are always allowed.
+func WithStrict() Option {
+	return func(l *Loader) {
+		l.strict = true
+	}
+}
+
+var validatorType = reflect.TypeOf((*Validator)(nil)).Elem()
+
+// validate calls Validate on v and every nested struct that implements Validator,
+// innermost first. Failures of nested structs are reported as FieldErrors with their
+// path, and all failures are returned together.
+func validate(v reflect.Value, path string) error {
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return nil
+	}
+
+	var errs []error
+	t := v.Type()
+	for i := 0; i < v.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		if f.Type.Kind() == reflect.Struct || (f.Type.Kind() == reflect.Ptr && f.Type.Elem().Kind() == reflect.Struct) {
+			errs = append(errs, flattenErrors(validate(v.Field(i), joinPath(path, keyName(f))))...)
+		}
+	}
+
+	if v.CanAddr() && v.Addr().Type().Implements(validatorType) {
+		if err := v.Addr().Interface().(Validator).Validate(); err != nil {
+			if path != "" {
+				err = &FieldError{Path: path, Err: err}
+			}
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// stripReserved removes the include and version keys from doc unless t has fields
+// for them, and reports whether doc was changed.
+func stripReserved(t reflect.Type, doc map[string]interface{}, format Format) bool {
+	changed := false
+	for _, key := range []string{includeKey, versionKey} {
+		if _, ok := doc[key]; !ok {
+			continue
+		}
+		if _, ok := fieldByKey(t, key, format); ok {
+			continue
+		}
+		delete(doc, key)
+		changed = true
+	}
+	return changed
+}
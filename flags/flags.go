@@ -0,0 +1,111 @@
+package flags
+
+import (
+	"context"
+	"hash/fnv"
+	"slices"
+	"sync"
+	"sync/atomic"
+
+	"github.com/fuguiw/fg-lib/config"
+	"github.com/fuguiw/fg-lib/log"
+)
+
+// Flag configures a single feature flag.
+type Flag struct {
+	// Enabled turns the flag on for everyone.
+	Enabled bool `yaml:"enabled" json:"enabled"`
+
+	// Percentage rolls the flag out to this share of users (0-100), bucketed by user ID.
+	Percentage float64 `yaml:"percentage" json:"percentage"`
+
+	// Users always have the flag enabled, regardless of Percentage.
+	Users []string `yaml:"users" json:"users"`
+}
+
+// Set evaluates feature flags read from a config.Loader and follows its refreshes.
+// The zero Set has no flags.
+type Set struct {
+	flags atomic.Pointer[map[string]Flag]
+}
+
+// New creates a Set from the Loader's current version and keeps it updated on every refresh.
+// get extracts the flags from the config struct, e.g.
+//
+//	func(cfg interface{}) map[string]flags.Flag { return cfg.(*AppConfig).Features }
+func New(l *config.Loader, get func(cfg interface{}) map[string]Flag) *Set {
+	s := &Set{}
+
+	// Watch before reading the current version, so none is missed in between. The
+	// lock keeps a callback from being overwritten by the older current version.
+	var mu sync.Mutex
+	mu.Lock()
+	defer mu.Unlock()
+	l.Watch(func(cfg interface{}) {
+		mu.Lock()
+		defer mu.Unlock()
+		s.Update(get(cfg))
+	})
+	if cfg := l.Current(); cfg != nil {
+		s.Update(get(cfg))
+	}
+	return s
+}
+
+// Update replaces all flags.
+func (s *Set) Update(flags map[string]Flag) {
+	m := make(map[string]Flag, len(flags))
+	for name, f := range flags {
+		m[name] = f
+	}
+	s.flags.Store(&m)
+}
+
+// Enabled reports whether the named flag is on for the user in ctx (see log.WithUserID).
+// Unknown flags are off. Percentage rollouts need a user ID and are stable per user and flag.
+func (s *Set) Enabled(ctx context.Context, name string) bool {
+	flags := s.flags.Load()
+	if flags == nil {
+		return false
+	}
+	f, ok := (*flags)[name]
+	if !ok {
+		return false
+	}
+	if f.Enabled {
+		return true
+	}
+
+	userID, _ := ctx.Value(log.UserIDKey).(string)
+	if userID == "" {
+		return false
+	}
+	if slices.Contains(f.Users, userID) {
+		return true
+	}
+	return f.Percentage > 0 && bucket(name, userID) < f.Percentage*100
+}
+
+// bucket maps a flag and user to a stable value in [0, 10000).
+func bucket(name, userID string) float64 {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(name + ":" + userID))
+	return float64(h.Sum32() % 10000)
+}
+
+var defaultSet atomic.Pointer[Set]
+
+// SetDefault makes s the Set used by the package-level Enabled.
+func SetDefault(s *Set) {
+	defaultSet.Store(s)
+}
+
+// Enabled reports whether the named flag is on in the default Set.
+// It returns false if SetDefault has not been called.
+func Enabled(ctx context.Context, name string) bool {
+	s := defaultSet.Load()
+	if s == nil {
+		return false
+	}
+	return s.Enabled(ctx, name)
+}
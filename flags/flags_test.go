@@ -0,0 +1,128 @@
+package flags
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/fuguiw/fg-lib/config"
+	"github.com/fuguiw/fg-lib/log"
+	"github.com/stretchr/testify/assert"
+)
+
+type appConfig struct {
+	Features map[string]Flag `yaml:"features"`
+}
+
+func newTestSet(t *testing.T, content string) (*Set, *config.Loader, string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	assert.NoError(t, os.WriteFile(path, []byte(content), 0644))
+
+	var cfg appConfig
+	l := config.NewLoader(&cfg, config.WithFile(path))
+	assert.NoError(t, l.Load())
+
+	s := New(l, func(cfg interface{}) map[string]Flag {
+		return cfg.(*appConfig).Features
+	})
+	return s, l, path
+}
+
+func TestSet_Enabled(t *testing.T) {
+	s, _, _ := newTestSet(t, `
+features:
+  on:
+    enabled: true
+  beta:
+    users: [alice]
+  half:
+    percentage: 50
+`)
+	anon := context.Background()
+	alice := log.WithUserID(anon, "alice")
+	bob := log.WithUserID(anon, "bob")
+
+	assert.True(t, s.Enabled(anon, "on"))
+	assert.False(t, s.Enabled(anon, "missing"))
+	assert.True(t, s.Enabled(alice, "beta"))
+	assert.False(t, s.Enabled(bob, "beta"))
+	assert.False(t, s.Enabled(anon, "half"))
+
+	enabled := 0
+	for i := 0; i < 1000; i++ {
+		ctx := log.WithUserID(anon, fmt.Sprintf("user-%d", i))
+		on := s.Enabled(ctx, "half")
+		assert.Equal(t, on, s.Enabled(ctx, "half"), "rollout must be stable per user")
+		if on {
+			enabled++
+		}
+	}
+	assert.InDelta(t, 500, enabled, 100)
+}
+
+func TestSet_HotRefresh(t *testing.T) {
+	s, l, path := newTestSet(t, "features:\n  new-ui:\n    enabled: false\n")
+	assert.False(t, s.Enabled(context.Background(), "new-ui"))
+
+	assert.NoError(t, os.WriteFile(path, []byte("features:\n  new-ui:\n    enabled: true\n"), 0644))
+	assert.NoError(t, l.Reload())
+	assert.True(t, s.Enabled(context.Background(), "new-ui"))
+}
+
+func TestSet_Zero(t *testing.T) {
+	var s Set
+	assert.False(t, s.Enabled(context.Background(), "on"))
+	s.Update(map[string]Flag{"on": {Enabled: true}})
+	assert.True(t, s.Enabled(context.Background(), "on"))
+}
+
+func TestNew_ConcurrentRefresh(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	write := func(i int) {
+		assert.NoError(t, os.WriteFile(path, []byte(fmt.Sprintf("features:\n  v%d:\n    enabled: true\n", i)), 0644))
+	}
+	write(0)
+	var cfg appConfig
+	l := config.NewLoader(&cfg, config.WithFile(path))
+	assert.NoError(t, l.Load())
+
+	// Versions applied while New runs must not be lost
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 1; ; i++ {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			write(i)
+			_ = l.Reload()
+		}
+	}()
+	sets := make([]*Set, 50)
+	for i := range sets {
+		sets[i] = New(l, func(cfg interface{}) map[string]Flag { return cfg.(*appConfig).Features })
+	}
+	close(stop)
+	<-done
+
+	for name := range l.Current().(*appConfig).Features {
+		for _, s := range sets {
+			assert.True(t, s.Enabled(context.Background(), name))
+		}
+	}
+}
+
+func TestEnabled_Default(t *testing.T) {
+	assert.False(t, Enabled(context.Background(), "on"))
+
+	s, _, _ := newTestSet(t, "features:\n  on:\n    enabled: true\n")
+	SetDefault(s)
+	defer SetDefault(nil)
+	assert.True(t, Enabled(context.Background(), "on"))
+}
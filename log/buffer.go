@@ -0,0 +1,130 @@
+package log
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+)
+
+const (
+	defaultBufferSize    = 256 * 1024
+	defaultFlushInterval = time.Second
+)
+
+func newBufferedWriter(ws zapcore.WriteSyncer, opts *BufferOptions) *bufferedWriter {
+	size := opts.Size
+	if size <= 0 {
+		size = defaultBufferSize
+	}
+	interval := opts.FlushInterval
+	if interval <= 0 {
+		interval = defaultFlushInterval
+	}
+	return &bufferedWriter{
+		buf: &zapcore.BufferedWriteSyncer{WS: ws, Size: size, FlushInterval: interval},
+		ws:  ws,
+	}
+}
+
+// bufferedWriter buffers writes until it is stopped and writes directly to the
+// underlying writer afterwards, so loggers derived from a closed handler lose nothing.
+type bufferedWriter struct {
+	buf *zapcore.BufferedWriteSyncer
+	ws  zapcore.WriteSyncer
+
+	// mu makes writes that started before Stop land in the buffer before it is flushed.
+	mu      sync.RWMutex
+	stopped bool
+}
+
+func (w *bufferedWriter) Write(p []byte) (int, error) {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	if w.stopped {
+		return w.ws.Write(p)
+	}
+	return w.buf.Write(p)
+}
+
+func (w *bufferedWriter) Sync() error {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	if w.stopped {
+		return w.ws.Sync()
+	}
+	return w.buf.Sync()
+}
+
+// Stop flushes the buffer and stops the background flushing.
+func (w *bufferedWriter) Stop() error {
+	w.mu.Lock()
+	w.stopped = true
+	w.mu.Unlock()
+	return w.buf.Stop()
+}
+
+type closerFunc func() error
+
+func (f closerFunc) Close() error {
+	return f()
+}
+
+// Sync flushes buffered entries of the logger installed by Init.
+func Sync() error {
+	mu.Lock()
+	defer mu.Unlock()
+	if current == nil {
+		return nil
+	}
+	return current.Sync()
+}
+
+// Close flushes and closes the logger installed by Init and restores the default
+// logger and the standard log package output from before Init, so later entries
+// still go somewhere. Levels are kept.
+// Loggers derived from the closed logger, e.g. with With or Named, keep writing to
+// its sinks without buffering.
+func Close() error {
+	return closeCurrent(context.Background())
+}
+
+// closeCurrent uninstalls the logger installed by Init and closes it, giving up when
+// ctx is done. The package lock is not held while closing.
+func closeCurrent(ctx context.Context) error {
+	mu.Lock()
+	old := current
+	current = nil
+	if old != nil {
+		restoreDefault()
+	}
+	mu.Unlock()
+
+	if old == nil {
+		return nil
+	}
+	return old.closeContext(ctx)
+}
+
+// ShutdownComponent is a graceful.Component that closes the logger on shutdown.
+// Components stop in reverse order of registration, so register it first to keep
+// logging until the other components have stopped:
+//
+//	g := graceful.New()
+//	g.Register(log.ShutdownComponent{}, server)
+type ShutdownComponent struct{}
+
+func (ShutdownComponent) Name() string {
+	return "log"
+}
+
+func (ShutdownComponent) Start(ctx context.Context) error {
+	return nil
+}
+
+// Stop closes the logger, giving up when ctx is done: pending OTLP exports are
+// cancelled, and writes blocked in other sinks finish in the background.
+func (ShutdownComponent) Stop(ctx context.Context) error {
+	return closeCurrent(ctx)
+}
@@ -3,6 +3,8 @@ package log
 import (
 	"context"
 	"log/slog"
+	"sync"
+	"sync/atomic"
 )
 
 type ctxKey string
@@ -11,6 +13,8 @@ const (
 	TraceIDKey ctxKey = "trace_id"
 	ReqIDKey   ctxKey = "request_id"
 	UserIDKey  ctxKey = "user_id"
+
+	fieldsKey ctxKey = "fields"
 )
 
 func WithTraceID(ctx context.Context, traceID string) context.Context {
@@ -25,17 +29,110 @@ func WithUserID(ctx context.Context, userID string) context.Context {
 	return context.WithValue(ctx, UserIDKey, userID)
 }
 
+// WithFields returns a context carrying attrs, which ZapHandler adds to every record
+// logged with it. Fields already in ctx are kept; later ones come after them.
+func WithFields(ctx context.Context, attrs ...slog.Attr) context.Context {
+	prev, _ := ctx.Value(fieldsKey).([]slog.Attr)
+	fields := make([]slog.Attr, 0, len(prev)+len(attrs))
+	fields = append(append(fields, prev...), attrs...)
+	return context.WithValue(ctx, fieldsKey, fields)
+}
+
+// Extractor returns the attributes to add to a record logged with ctx.
+type Extractor func(ctx context.Context) []slog.Attr
+
+// ValueExtractor returns an Extractor adding the value stored in ctx under key
+// as the attribute name, if present.
+func ValueExtractor(key interface{}, name string) Extractor {
+	return func(ctx context.Context) []slog.Attr {
+		v := ctx.Value(key)
+		if v == nil {
+			return nil
+		}
+		return []slog.Attr{slog.Any(name, v)}
+	}
+}
+
+type namedExtractor struct {
+	name string
+	fn   Extractor
+}
+
+var (
+	extractorsMu sync.Mutex
+
+	// extractors is replaced on change, so Handle reads it without locking.
+	extractors atomic.Pointer[[]namedExtractor]
+)
+
+func init() {
+	RegisterExtractor("trace_id", stringExtractor(TraceIDKey, "trace_id"))
+	RegisterExtractor("request_id", stringExtractor(ReqIDKey, "request_id"))
+	RegisterExtractor("user_id", stringExtractor(UserIDKey, "user_id"))
+	RegisterExtractor("fields", func(ctx context.Context) []slog.Attr {
+		attrs, _ := ctx.Value(fieldsKey).([]slog.Attr)
+		return attrs
+	})
+}
+
+// RegisterExtractor adds fn to the extractors ZapHandler runs on the context of every
+// record, e.g. for a tenant or session ID. Extractors run in registration order;
+// registering a name again replaces its extractor in place. The built-in extractors
+// are "trace_id", "request_id", "user_id" and "fields" (for WithFields).
+func RegisterExtractor(name string, fn Extractor) {
+	extractorsMu.Lock()
+	defer extractorsMu.Unlock()
+
+	var list []namedExtractor
+	if p := extractors.Load(); p != nil {
+		list = append(list, *p...)
+	}
+	replaced := false
+	for i := range list {
+		if list[i].name == name {
+			list[i].fn = fn
+			replaced = true
+		}
+	}
+	if !replaced {
+		list = append(list, namedExtractor{name: name, fn: fn})
+	}
+	extractors.Store(&list)
+}
+
+// UnregisterExtractor removes the extractor registered under name.
+func UnregisterExtractor(name string) {
+	extractorsMu.Lock()
+	defer extractorsMu.Unlock()
+
+	var list []namedExtractor
+	if p := extractors.Load(); p != nil {
+		for _, e := range *p {
+			if e.name != name {
+				list = append(list, e)
+			}
+		}
+	}
+	extractors.Store(&list)
+}
+
+func stringExtractor(key ctxKey, name string) Extractor {
+	return func(ctx context.Context) []slog.Attr {
+		if v, ok := ctx.Value(key).(string); ok {
+			return []slog.Attr{slog.String(name, v)}
+		}
+		return nil
+	}
+}
+
 // contextFields returns a list of slog.Attr from the context.
 func contextFields(ctx context.Context) []slog.Attr {
-	var attrs []slog.Attr
-	if v, ok := ctx.Value(TraceIDKey).(string); ok {
-		attrs = append(attrs, slog.String("trace_id", v))
-	}
-	if v, ok := ctx.Value(ReqIDKey).(string); ok {
-		attrs = append(attrs, slog.String("request_id", v))
+	if ctx == nil {
+		return nil
 	}
-	if v, ok := ctx.Value(UserIDKey).(string); ok {
-		attrs = append(attrs, slog.String("user_id", v))
+	var attrs []slog.Attr
+	for _, e := range *extractors.Load() {
+		attrs = append(attrs, e.fn(ctx)...)
 	}
 	return attrs
 }
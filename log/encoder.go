@@ -0,0 +1,171 @@
+package log
+
+import (
+	"time"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// TimeFormat selects how timestamps are encoded.
+type TimeFormat string
+
+const (
+	TimeRFC3339     TimeFormat = "rfc3339"
+	TimeRFC3339Nano TimeFormat = "rfc3339nano"
+	TimeISO8601     TimeFormat = "iso8601"
+	TimeEpoch       TimeFormat = "epoch"        // seconds as a float
+	TimeEpochMillis TimeFormat = "epoch_millis" // integer milliseconds
+	TimeEpochNanos  TimeFormat = "epoch_nanos"  // integer nanoseconds
+)
+
+// LevelFormat selects how levels are encoded.
+type LevelFormat string
+
+const (
+	LevelLower LevelFormat = "lower" // info
+	LevelUpper LevelFormat = "upper" // INFO
+
+	// LevelGCP uses Google Cloud Logging severities: DEBUG, INFO, WARNING, ERROR, CRITICAL, ...
+	LevelGCP LevelFormat = "gcp"
+)
+
+// CallerFormat selects how the caller is encoded.
+type CallerFormat string
+
+const (
+	CallerShort CallerFormat = "short" // package/file.go:42
+	CallerFull  CallerFormat = "full"  // /abs/path/package/file.go:42
+)
+
+// OmitKey as a key in EncoderOptions leaves the field out.
+const OmitKey = "-"
+
+// EncoderOptions controls the field names and formats of encoded records, to match the
+// schema a log pipeline ingests. Empty fields keep the defaults of the format.
+type EncoderOptions struct {
+	TimeKey       string
+	LevelKey      string
+	NameKey       string
+	CallerKey     string
+	FunctionKey   string
+	MessageKey    string
+	StacktraceKey string
+
+	// TimeFormat is a TimeFormat constant or a Go time layout; defaults to TimeRFC3339.
+	TimeFormat TimeFormat
+
+	// LevelFormat defaults to LevelLower for JSON and colored upper case for text.
+	LevelFormat LevelFormat
+
+	// CallerFormat defaults to CallerShort.
+	CallerFormat CallerFormat
+}
+
+// ECSEncoderOptions returns field names following the Elastic Common Schema.
+func ECSEncoderOptions() *EncoderOptions {
+	return &EncoderOptions{
+		TimeKey:       "@timestamp",
+		LevelKey:      "log.level",
+		NameKey:       "log.logger",
+		CallerKey:     "log.origin",
+		MessageKey:    "message",
+		StacktraceKey: "error.stack_trace",
+		TimeFormat:    TimeISO8601,
+		LevelFormat:   LevelLower,
+	}
+}
+
+// GCPEncoderOptions returns field names understood by Google Cloud Logging.
+func GCPEncoderOptions() *EncoderOptions {
+	return &EncoderOptions{
+		TimeKey:       "time",
+		LevelKey:      "severity",
+		NameKey:       "logger",
+		CallerKey:     "caller",
+		MessageKey:    "message",
+		StacktraceKey: "stack_trace",
+		TimeFormat:    TimeRFC3339Nano,
+		LevelFormat:   LevelGCP,
+	}
+}
+
+// apply overrides cfg with the set options.
+func (o *EncoderOptions) apply(cfg *zapcore.EncoderConfig) {
+	setKey(&cfg.TimeKey, o.TimeKey)
+	setKey(&cfg.LevelKey, o.LevelKey)
+	setKey(&cfg.NameKey, o.NameKey)
+	setKey(&cfg.CallerKey, o.CallerKey)
+	setKey(&cfg.FunctionKey, o.FunctionKey)
+	setKey(&cfg.MessageKey, o.MessageKey)
+	setKey(&cfg.StacktraceKey, o.StacktraceKey)
+
+	if o.TimeFormat != "" {
+		cfg.EncodeTime = timeEncoder(o.TimeFormat)
+	}
+	switch o.LevelFormat {
+	case LevelLower:
+		cfg.EncodeLevel = zapcore.LowercaseLevelEncoder
+	case LevelUpper:
+		cfg.EncodeLevel = zapcore.CapitalLevelEncoder
+	case LevelGCP:
+		cfg.EncodeLevel = gcpLevelEncoder
+	}
+	switch o.CallerFormat {
+	case CallerShort:
+		cfg.EncodeCaller = zapcore.ShortCallerEncoder
+	case CallerFull:
+		cfg.EncodeCaller = zapcore.FullCallerEncoder
+	}
+}
+
+func setKey(dst *string, key string) {
+	switch key {
+	case "":
+	case OmitKey:
+		*dst = zapcore.OmitKey
+	default:
+		*dst = key
+	}
+}
+
+func timeEncoder(f TimeFormat) zapcore.TimeEncoder {
+	switch f {
+	case TimeRFC3339:
+		return zapcore.RFC3339TimeEncoder
+	case TimeRFC3339Nano:
+		return zapcore.RFC3339NanoTimeEncoder
+	case TimeISO8601:
+		return zapcore.ISO8601TimeEncoder
+	case TimeEpoch:
+		return zapcore.EpochTimeEncoder
+	case TimeEpochMillis:
+		return func(t time.Time, enc zapcore.PrimitiveArrayEncoder) {
+			enc.AppendInt64(t.UnixMilli())
+		}
+	case TimeEpochNanos:
+		return zapcore.EpochNanosTimeEncoder
+	default:
+		return zapcore.TimeEncoderOfLayout(string(f))
+	}
+}
+
+func gcpLevelEncoder(l zapcore.Level, enc zapcore.PrimitiveArrayEncoder) {
+	switch l {
+	case zapcore.DebugLevel:
+		enc.AppendString("DEBUG")
+	case zapcore.InfoLevel:
+		enc.AppendString("INFO")
+	case zapcore.WarnLevel:
+		enc.AppendString("WARNING")
+	case zapcore.ErrorLevel:
+		enc.AppendString("ERROR")
+	case zapcore.DPanicLevel:
+		enc.AppendString("CRITICAL")
+	case zapcore.PanicLevel:
+		enc.AppendString("ALERT")
+	case zapcore.FatalLevel:
+		enc.AppendString("EMERGENCY")
+	default:
+		enc.AppendString("DEFAULT")
+	}
+}
@@ -0,0 +1,197 @@
+package log
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// levelsResponse is the body returned by the level handler.
+type levelsResponse struct {
+	Level    slog.Level    `json:"level"`
+	Modules  []ModuleLevel `json:"modules"`
+	RevertAt *time.Time    `json:"revert_at,omitempty"`
+}
+
+// levelsRequest is the body accepted by PUT. Module levels set to "" are unset.
+type levelsRequest struct {
+	Level       string            `json:"level"`
+	Modules     map[string]string `json:"modules"`
+	RevertAfter string            `json:"revert_after"`
+}
+
+// levelState is a snapshot of the global and explicit module levels.
+type levelState struct {
+	level   slog.Level
+	modules map[string]slog.Level
+}
+
+// levelHandler serves NewLevelHandler and tracks a pending revert.
+type levelHandler struct {
+	mu       sync.Mutex
+	timer    *time.Timer
+	saved    *levelState
+	revertAt time.Time
+
+	// gen identifies the current timer, so a timer that fired while being replaced does nothing.
+	gen int
+}
+
+// NewLevelHandler returns an http.Handler for viewing and changing log levels at runtime.
+//
+// GET (and HEAD) returns the global level and the modules from ModuleLevels as JSON.
+// PUT takes a JSON body such as
+//
+//	{"level": "debug", "modules": {"db": "debug", "cache": ""}, "revert_after": "15m"}
+//
+// where every field is optional and "" unsets a module level. With revert_after, the
+// levels from before the change are restored once the duration has passed; further
+// changes with revert_after extend the deadline, changes without it cancel the revert.
+func NewLevelHandler() http.Handler {
+	return &levelHandler{}
+}
+
+func (h *levelHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+	case http.MethodPut:
+		if err := h.update(r); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	default:
+		w.Header().Set("Allow", "GET, HEAD, PUT")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	resp := levelsResponse{Level: GetLevel(), Modules: ModuleLevels()}
+	h.mu.Lock()
+	if h.timer != nil {
+		at := h.revertAt
+		resp.RevertAt = &at
+	}
+	h.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	_ = enc.Encode(resp)
+}
+
+// update parses the request and applies it; nothing is changed if any level is invalid.
+func (h *levelHandler) update(r *http.Request) error {
+	var req levelsRequest
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&req); err != nil {
+		return fmt.Errorf("invalid body: %w", err)
+	}
+
+	var level *slog.Level
+	if req.Level != "" {
+		l, err := parseLevel(req.Level)
+		if err != nil {
+			return err
+		}
+		level = &l
+	}
+	modules := make(map[string]*slog.Level, len(req.Modules))
+	for module, name := range req.Modules {
+		if name == "" {
+			modules[module] = nil
+			continue
+		}
+		l, err := parseLevel(name)
+		if err != nil {
+			return fmt.Errorf("module %s: %w", module, err)
+		}
+		modules[module] = &l
+	}
+	var revertAfter time.Duration
+	if req.RevertAfter != "" {
+		d, err := time.ParseDuration(req.RevertAfter)
+		if err != nil || d <= 0 {
+			return fmt.Errorf("invalid revert_after %q", req.RevertAfter)
+		}
+		revertAfter = d
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if revertAfter > 0 {
+		if h.saved == nil {
+			h.saved = snapshotLevels()
+		}
+		if h.timer != nil {
+			h.timer.Stop()
+		}
+		h.gen++
+		gen := h.gen
+		h.revertAt = time.Now().Add(revertAfter)
+		h.timer = time.AfterFunc(revertAfter, func() { h.revert(gen) })
+	} else if h.timer != nil {
+		h.timer.Stop()
+		h.timer, h.saved = nil, nil
+		h.gen++
+	}
+
+	if level != nil {
+		SetLevel(*level)
+	}
+	for module, l := range modules {
+		if l == nil {
+			UnsetModuleLevel(module)
+		} else {
+			SetModuleLevel(module, *l)
+		}
+	}
+	slog.Info("log: levels changed", "level", GetLevel(), "modules", req.Modules, "revert_after", req.RevertAfter)
+	return nil
+}
+
+// revert restores the levels saved before the first change with revert_after.
+func (h *levelHandler) revert(gen int) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if gen != h.gen || h.saved == nil {
+		return
+	}
+	h.saved.restore()
+	h.timer, h.saved = nil, nil
+	slog.Info("log: levels reverted", "level", GetLevel())
+}
+
+func snapshotLevels() *levelState {
+	moduleLevels.mu.RLock()
+	defer moduleLevels.mu.RUnlock()
+	s := &levelState{level: GetLevel(), modules: make(map[string]slog.Level, len(moduleLevels.levels))}
+	for module, l := range moduleLevels.levels {
+		s.modules[module] = l
+	}
+	return s
+}
+
+func (s *levelState) restore() {
+	SetLevel(s.level)
+	for _, m := range ModuleLevels() {
+		if _, ok := s.modules[m.Module]; !ok && m.Explicit {
+			UnsetModuleLevel(m.Module)
+		}
+	}
+	for module, l := range s.modules {
+		SetModuleLevel(module, l)
+	}
+}
+
+func parseLevel(s string) (slog.Level, error) {
+	var l slog.Level
+	if err := l.UnmarshalText([]byte(s)); err != nil {
+		return 0, fmt.Errorf("invalid log level %q", s)
+	}
+	return l, nil
+}
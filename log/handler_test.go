@@ -0,0 +1,77 @@
+package log
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLevelHandler(t *testing.T) {
+	assert.NoError(t, Reset())
+	defer Reset()
+	srv := httptest.NewServer(NewLevelHandler())
+	defer srv.Close()
+
+	put := func(body string) *http.Response {
+		req, _ := http.NewRequest(http.MethodPut, srv.URL, strings.NewReader(body))
+		resp, err := http.DefaultClient.Do(req)
+		assert.NoError(t, err)
+		return resp
+	}
+
+	resp := put(`{"level": "warn", "modules": {"db": "debug"}}`)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	resp.Body.Close()
+	assert.Equal(t, slog.LevelWarn, GetLevel())
+	assert.Equal(t, slog.LevelDebug, EffectiveLevel("db.pool"))
+
+	resp, err := http.Get(srv.URL)
+	assert.NoError(t, err)
+	var body struct {
+		Level   string `json:"level"`
+		Modules []struct {
+			Module   string `json:"module"`
+			Level    string `json:"level"`
+			Explicit bool   `json:"explicit"`
+		} `json:"modules"`
+	}
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+	resp.Body.Close()
+	assert.Equal(t, "WARN", body.Level)
+	if assert.Len(t, body.Modules, 1) {
+		assert.Equal(t, "db", body.Modules[0].Module)
+		assert.Equal(t, "DEBUG", body.Modules[0].Level)
+	}
+
+	// Invalid levels change nothing.
+	resp = put(`{"level": "error", "modules": {"db": "loud"}}`)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	resp.Body.Close()
+	assert.Equal(t, slog.LevelWarn, GetLevel())
+
+	resp = put(`{"level": "debug", "modules": {"db": "", "cache": "error"}, "revert_after": "50ms"}`)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	resp.Body.Close()
+	assert.Equal(t, slog.LevelDebug, GetLevel())
+	_, ok := GetModuleLevel("db")
+	assert.False(t, ok)
+
+	assert.Eventually(t, func() bool { return GetLevel() == slog.LevelWarn }, time.Second, 10*time.Millisecond)
+	level, ok := GetModuleLevel("db")
+	assert.True(t, ok)
+	assert.Equal(t, slog.LevelDebug, level)
+	_, ok = GetModuleLevel("cache")
+	assert.False(t, ok)
+
+	req, _ := http.NewRequest(http.MethodDelete, srv.URL, nil)
+	resp, err = http.DefaultClient.Do(req)
+	assert.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode)
+}
@@ -1,6 +1,11 @@
 package log
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	stdlog "log"
 	"log/slog"
 	"os"
 	"path/filepath"
@@ -8,17 +13,13 @@ import (
 	"testing"
 	"time"
 
+	"github.com/fuguiw/fg-lib/graceful"
 	"github.com/stretchr/testify/assert"
 	"go.uber.org/zap"
 )
 
 func TestLogToFile(t *testing.T) {
-	// Create a temporary directory for logs
-	tmpDir, err := os.MkdirTemp("", "log_test")
-	assert.NoError(t, err)
-	defer os.RemoveAll(tmpDir)
-
-	logFile := filepath.Join(tmpDir, "test.log")
+	logFile := filepath.Join(t.TempDir(), "test.log")
 
 	// Initialize logger
 	opts := DefaultOptions()
@@ -27,17 +28,15 @@ func TestLogToFile(t *testing.T) {
 	opts.Format = FormatJSON
 	opts.Level = slog.LevelInfo
 
-	// We can't re-init the global logger easily because of sync.Once in Init.
-	// So we will test NewZapHandler directly.
-	handler := NewZapHandler(opts, zap.NewAtomicLevelAt(toZapLevel(opts.Level)))
-	logger := slog.New(handler)
+	_, err := Init(opts)
+	assert.NoError(t, err)
 
 	// Write a log
 	msg := "test log message to file"
-	logger.Info(msg)
+	slog.Info(msg)
 
-	// Sleep briefly to ensure write flush
-	time.Sleep(100 * time.Millisecond)
+	// Reset flushes and closes the file
+	assert.NoError(t, Reset())
 
 	// Check file content
 	content, err := os.ReadFile(logFile)
@@ -45,3 +44,463 @@ func TestLogToFile(t *testing.T) {
 	assert.True(t, len(content) > 0, "Log file should not be empty")
 	assert.True(t, strings.Contains(string(content), msg), "Log file should contain the message")
 }
+
+func TestInit_Replace(t *testing.T) {
+	defer Reset()
+	dir := t.TempDir()
+	first, second := filepath.Join(dir, "first.log"), filepath.Join(dir, "second.log")
+
+	before := slog.Default().Handler()
+	prev, err := Init(&Options{Level: slog.LevelInfo, Format: FormatJSON, FilePath: first})
+	assert.NoError(t, err)
+	assert.Equal(t, before, prev)
+	slog.Info("to first")
+	firstHandler := slog.Default().Handler()
+
+	prev, err = Init(&Options{Level: slog.LevelWarn, Format: FormatText, FilePath: second})
+	assert.NoError(t, err)
+	assert.Same(t, firstHandler, prev)
+	assert.Equal(t, slog.LevelWarn, GetLevel())
+	slog.Warn("to second")
+
+	assert.NoError(t, Reset())
+	assert.Equal(t, before, slog.Default().Handler())
+	assert.Equal(t, slog.LevelInfo, GetLevel())
+
+	content, err := os.ReadFile(first)
+	assert.NoError(t, err)
+	assert.Contains(t, string(content), "to first")
+	assert.NotContains(t, string(content), "to second")
+
+	content, err = os.ReadFile(second)
+	assert.NoError(t, err)
+	assert.Contains(t, string(content), "to second")
+}
+
+func TestNamedModuleLevel(t *testing.T) {
+	logFile := filepath.Join(t.TempDir(), "module.log")
+	opts := DefaultOptions()
+	opts.Output = OutputFile
+	opts.FilePath = logFile
+
+	old := slog.Default()
+	defer slog.SetDefault(old)
+	slog.SetDefault(slog.New(NewZapHandler(opts, zap.NewAtomicLevelAt(zap.InfoLevel))))
+
+	db := Named("db")
+	SetModuleLevel("db", slog.LevelDebug)
+	defer UnsetModuleLevel("db")
+
+	db.Debug("db debug")
+	db.With("table", "users").Debug("db debug with attrs")
+	slog.Debug("root debug")
+
+	UnsetModuleLevel("db")
+	db.Debug("db debug after unset")
+
+	content, err := os.ReadFile(logFile)
+	assert.NoError(t, err)
+	assert.Contains(t, string(content), `"msg":"db debug"`)
+	assert.Contains(t, string(content), `"logger":"db"`)
+	assert.Contains(t, string(content), `"table":"users"`)
+	assert.NotContains(t, string(content), "root debug")
+	assert.NotContains(t, string(content), "after unset")
+}
+
+func TestSinks(t *testing.T) {
+	defer Reset()
+	logFile := filepath.Join(t.TempDir(), "json.log")
+	var text bytes.Buffer
+
+	_, err := Init(&Options{
+		Level: slog.LevelDebug,
+		Sinks: []Sink{
+			{Format: FormatJSON, Output: OutputFile, FilePath: logFile},
+			{Format: FormatText, Writer: &text, Level: slog.LevelWarn},
+		},
+	})
+	assert.NoError(t, err)
+
+	slog.Debug("debug only in file")
+	slog.Warn("warn everywhere", "k", "v")
+	assert.NoError(t, Reset())
+
+	content, err := os.ReadFile(logFile)
+	assert.NoError(t, err)
+	assert.Contains(t, string(content), `"msg":"debug only in file"`)
+	assert.Contains(t, string(content), `"msg":"warn everywhere"`)
+
+	assert.NotContains(t, text.String(), "debug only in file")
+	assert.Contains(t, text.String(), "warn everywhere")
+	assert.Contains(t, text.String(), `{"k": "v"}`)
+}
+
+func TestModuleLevels_Hierarchy(t *testing.T) {
+	assert.NoError(t, Reset())
+	defer Reset()
+	var buf bytes.Buffer
+	_, err := Init(&Options{Level: slog.LevelInfo, Sinks: []Sink{{Format: FormatJSON, Writer: &buf}}})
+	assert.NoError(t, err)
+
+	pool := Named("db.pool")
+	SetModuleLevel("db", slog.LevelDebug)
+	defer UnsetModuleLevel("db")
+
+	pool.Debug("pool debug inherited")
+	assert.Equal(t, slog.LevelDebug, EffectiveLevel("db.pool"))
+	assert.Equal(t, slog.LevelInfo, EffectiveLevel("cache"))
+
+	SetModuleLevel("db.pool", slog.LevelError)
+	defer UnsetModuleLevel("db.pool")
+	pool.Warn("pool warn suppressed")
+
+	assert.Contains(t, buf.String(), "pool debug inherited")
+	assert.NotContains(t, buf.String(), "pool warn suppressed")
+
+	UnsetModuleLevel("db")
+	levels := make(map[string]ModuleLevel)
+	for _, ml := range ModuleLevels() {
+		levels[ml.Module] = ml
+	}
+	assert.Equal(t, ModuleLevel{Module: "db.pool", Level: slog.LevelError, Explicit: true}, levels["db.pool"])
+	assert.NotContains(t, levels, "db")
+}
+
+func TestSampling(t *testing.T) {
+	assert.NoError(t, Reset())
+	defer Reset()
+	var buf bytes.Buffer
+	_, err := Init(&Options{
+		Level:    slog.LevelInfo,
+		Sinks:    []Sink{{Format: FormatJSON, Writer: &buf}},
+		Sampling: &SamplingOptions{Tick: time.Minute, First: 3, Thereafter: 5},
+	})
+	assert.NoError(t, err)
+
+	for i := 0; i < 13; i++ {
+		slog.Error("hot loop", "i", i)
+	}
+	slog.Error("other message")
+
+	// 3 first, then the 5th and 10th of the remaining 10, plus the other message
+	assert.Equal(t, 6, strings.Count(buf.String(), "\n"))
+	assert.Equal(t, SamplingStats{Logged: 6, Dropped: 8}, GetSamplingStats())
+}
+
+func TestBuffer(t *testing.T) {
+	assert.NoError(t, Reset())
+	defer Reset()
+	logFile := filepath.Join(t.TempDir(), "buffered.log")
+	_, err := Init(&Options{
+		Level:    slog.LevelInfo,
+		Format:   FormatJSON,
+		FilePath: logFile,
+		Buffer:   &BufferOptions{FlushInterval: time.Hour},
+	})
+	assert.NoError(t, err)
+
+	slog.Info("buffered entry")
+	content, _ := os.ReadFile(logFile)
+	assert.NotContains(t, string(content), "buffered entry")
+
+	assert.NoError(t, Sync())
+	content, err = os.ReadFile(logFile)
+	assert.NoError(t, err)
+	assert.Contains(t, string(content), "buffered entry")
+
+	// Stopping the shutdown component flushes what is left and closes the file.
+	var c graceful.Component = ShutdownComponent{}
+	slog.Info("entry during shutdown")
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	assert.NoError(t, c.Stop(ctx))
+
+	content, err = os.ReadFile(logFile)
+	assert.NoError(t, err)
+	assert.Contains(t, string(content), "entry during shutdown")
+	assert.Equal(t, original, slog.Default())
+	assert.NoError(t, Close())
+}
+
+// blockingWriter blocks every write until release is closed.
+type blockingWriter struct {
+	release chan struct{}
+}
+
+func (w blockingWriter) Write(p []byte) (int, error) {
+	<-w.release
+	return len(p), nil
+}
+
+func TestShutdownComponent_Timeout(t *testing.T) {
+	assert.NoError(t, Reset())
+	defer Reset()
+	w := blockingWriter{release: make(chan struct{})}
+	defer close(w.release)
+	_, err := Init(&Options{
+		Level:  slog.LevelInfo,
+		Sinks:  []Sink{{Format: FormatJSON, Writer: w}},
+		Buffer: &BufferOptions{FlushInterval: time.Hour},
+	})
+	assert.NoError(t, err)
+	slog.Info("stuck in the buffer")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	assert.ErrorIs(t, ShutdownComponent{}.Stop(ctx), context.DeadlineExceeded)
+	assert.Less(t, time.Since(start), time.Second)
+
+	// The writer is still blocked, but the logger can be replaced
+	var buf bytes.Buffer
+	_, err = Init(&Options{Level: slog.LevelInfo, Sinks: []Sink{{Format: FormatJSON, Writer: &buf}}})
+	assert.NoError(t, err)
+	slog.Info("new logger")
+	assert.Contains(t, buf.String(), "new logger")
+}
+
+func TestClose_DerivedUnbuffered(t *testing.T) {
+	assert.NoError(t, Reset())
+	defer Reset()
+	var buf bytes.Buffer
+	_, err := Init(&Options{
+		Level:  slog.LevelInfo,
+		Sinks:  []Sink{{Format: FormatJSON, Writer: &buf}},
+		Buffer: &BufferOptions{FlushInterval: time.Hour},
+	})
+	assert.NoError(t, err)
+	logger := slog.Default().With("component", "worker")
+	db := Named("db")
+
+	assert.NoError(t, Close())
+	logger.Info("after close")
+	db.Info("named after close")
+	assert.Contains(t, buf.String(), `"msg":"after close","component":"worker"`)
+	assert.Contains(t, buf.String(), "named after close")
+}
+
+func TestRestoresStandardLog(t *testing.T) {
+	assert.NoError(t, Reset())
+	defer Reset()
+	var std bytes.Buffer
+	prevWriter, prevFlags := stdlog.Writer(), stdlog.Flags()
+	stdlog.SetOutput(&std)
+	stdlog.SetFlags(stdlog.Lmsgprefix)
+	defer func() {
+		stdlog.SetOutput(prevWriter)
+		stdlog.SetFlags(prevFlags)
+	}()
+
+	for name, uninstall := range map[string]func() error{"Reset": Reset, "Close": Close} {
+		t.Run(name, func(t *testing.T) {
+			std.Reset()
+			var sink bytes.Buffer
+			_, err := Init(&Options{Level: slog.LevelInfo, Sinks: []Sink{{Format: FormatJSON, Writer: &sink}}})
+			assert.NoError(t, err)
+			stdlog.Print("while installed")
+			assert.Contains(t, sink.String(), "while installed")
+
+			assert.NoError(t, uninstall())
+			sink.Reset()
+			slog.Info("after via slog")
+			stdlog.Print("after via log")
+			assert.Empty(t, sink.String())
+			assert.Equal(t, "INFO after via slog\nafter via log\n", std.String())
+			assert.Equal(t, stdlog.Lmsgprefix, stdlog.Flags())
+		})
+	}
+}
+
+type tenantKey struct{}
+
+func TestContextExtractors(t *testing.T) {
+	assert.NoError(t, Reset())
+	defer Reset()
+	var buf bytes.Buffer
+	_, err := Init(&Options{Level: slog.LevelInfo, Sinks: []Sink{{Format: FormatJSON, Writer: &buf}}})
+	assert.NoError(t, err)
+
+	RegisterExtractor("tenant", ValueExtractor(tenantKey{}, "tenant"))
+	defer UnregisterExtractor("tenant")
+
+	ctx := WithRequestID(context.Background(), "req-1")
+	ctx = context.WithValue(ctx, tenantKey{}, "acme")
+	ctx = WithFields(ctx, slog.String("session", "s-1"))
+	ctx = WithFields(ctx, slog.Int("attempt", 2))
+	slog.InfoContext(ctx, "with fields")
+
+	out := buf.String()
+	assert.Contains(t, out, `"request_id":"req-1"`)
+	assert.Contains(t, out, `"tenant":"acme"`)
+	assert.Contains(t, out, `"session":"s-1"`)
+	assert.Contains(t, out, `"attempt":2`)
+
+	UnregisterExtractor("tenant")
+	buf.Reset()
+	slog.InfoContext(ctx, "without tenant")
+	assert.NotContains(t, buf.String(), "acme")
+	assert.Contains(t, buf.String(), `"session":"s-1"`)
+}
+
+type apiKey string
+
+func (apiKey) Sensitive() bool { return true }
+
+type credentials struct {
+	User string
+	Key  apiKey
+}
+
+func (c credentials) LogValue() slog.Value {
+	return slog.GroupValue(slog.String("user", c.User), slog.Any("key", c.Key))
+}
+
+func TestRedact(t *testing.T) {
+	assert.NoError(t, Reset())
+	defer Reset()
+	var buf bytes.Buffer
+	_, err := Init(&Options{
+		Level:  slog.LevelInfo,
+		Sinks:  []Sink{{Format: FormatJSON, Writer: &buf}},
+		Redact: DefaultRedactOptions(),
+	})
+	assert.NoError(t, err)
+
+	logger := slog.Default().With("db_password", "hunter2")
+	logger.Info("signup from alice@example.com",
+		"card", "4111 1111 1111 1111",
+		slog.Group("headers", "Authorization", "Bearer abc", "Accept", "text/html"),
+		"jwt", "token=eyJhbGciOiJIUzI1NiJ9.eyJzdWIiOiIxIn0.sig_1",
+		"creds", credentials{User: "alice", Key: "k-123"},
+		"attempts", 3,
+	)
+
+	out := buf.String()
+	for _, secret := range []string{"hunter2", "alice@example.com", "4111", "Bearer abc", "eyJ", "k-123"} {
+		assert.NotContains(t, out, secret)
+	}
+	assert.Contains(t, out, `"msg":"signup from [REDACTED]"`)
+	assert.Contains(t, out, `"db_password":"[REDACTED]"`)
+	assert.Contains(t, out, `"Accept":"text/html"`)
+	assert.Contains(t, out, `"jwt":"token=[REDACTED]"`)
+	assert.Contains(t, out, `"user":"alice"`)
+	assert.Contains(t, out, `"attempts":3`)
+}
+
+func TestRedact_Any(t *testing.T) {
+	assert.NoError(t, Reset())
+	defer Reset()
+	var buf bytes.Buffer
+	_, err := Init(&Options{
+		Level:  slog.LevelInfo,
+		Sinks:  []Sink{{Format: FormatJSON, Writer: &buf}},
+		Redact: DefaultRedactOptions(),
+	})
+	assert.NoError(t, err)
+
+	type inner struct {
+		ByName map[string]apiKey
+		Note   string
+	}
+	type request struct {
+		Key   apiKey
+		Keys  []apiKey
+		Inner *inner `json:"inner,omitempty"`
+	}
+	type login struct {
+		User     string `json:"user"`
+		Password string `json:"password"`
+		Email    string `json:"email"`
+	}
+
+	tests := []struct {
+		name  string
+		value interface{}
+		want  string
+	}{
+		{"map", map[string]interface{}{"apiKey": "k-123", "n": 9007199254740993}, `{"apiKey":"[REDACTED]","n":9007199254740993}`},
+		{"struct", login{User: "alice", Password: "hunter2", Email: "alice@example.com"}, `{"email":"[REDACTED]","password":"[REDACTED]","user":"alice"}`},
+		{"pointer", &login{User: "bob", Password: "hunter2"}, `{"email":"","password":"[REDACTED]","user":"bob"}`},
+		{"slice", []map[string]string{{"token": "abc"}}, `[{"token":"[REDACTED]"}]`},
+		{"error", errors.New("send to alice@example.com: timeout"), `"send to [REDACTED]: timeout"`},
+		{"nested sensitive", request{
+			Key:   "s3cr3t",
+			Keys:  []apiKey{"inlist"},
+			Inner: &inner{ByName: map[string]apiKey{"primary": "inmap"}, Note: "ok"},
+		}, `{"Key":"[REDACTED]","Keys":["[REDACTED]"],"inner":{"ByName":{"primary":"[REDACTED]"},"Note":"ok"}}`},
+		{"sensitive slice", []apiKey{"inlist"}, `["[REDACTED]"]`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buf.Reset()
+			slog.Info("any", "v", tt.value)
+
+			var entry map[string]json.RawMessage
+			assert.NoError(t, json.Unmarshal(buf.Bytes(), &entry))
+			assert.JSONEq(t, tt.want, string(entry["v"]))
+		})
+	}
+}
+
+func TestRedact_Keys(t *testing.T) {
+	r := newRedactor(DefaultRedactOptions())
+	for _, key := range []string{"password", "db_password", "dbPassword", "X-Api-Key", "APIKey", "access.token", "Set-Cookie"} {
+		assert.True(t, r.sensitiveKey(key), key)
+	}
+	for _, key := range []string{"max_tokens", "tokenizer", "secretary", "passwords_checked", "api"} {
+		assert.False(t, r.sensitiveKey(key), key)
+	}
+}
+
+func TestRedact_CardLuhn(t *testing.T) {
+	r := newRedactor(DefaultRedactOptions())
+	assert.Equal(t, "card [REDACTED]", r.string("card 4111 1111 1111 1111"))
+	assert.Equal(t, "card [REDACTED]", r.string("card 4111-1111-1111-1111"))
+	assert.Equal(t, "order 1234567890123", r.string("order 1234567890123"))
+	assert.Equal(t, "id 4111 1111 1111 1112", r.string("id 4111 1111 1111 1112"))
+}
+
+func TestEncoderOptions(t *testing.T) {
+	assert.NoError(t, Reset())
+	defer Reset()
+	var gcp, custom bytes.Buffer
+	_, err := Init(&Options{
+		Level:        slog.LevelInfo,
+		EnableCaller: true,
+		Encoder:      GCPEncoderOptions(),
+		Sinks:        []Sink{{Format: FormatJSON, Writer: &gcp}},
+	})
+	assert.NoError(t, err)
+	Named("db").Warn("slow query")
+
+	var entry map[string]interface{}
+	assert.NoError(t, json.Unmarshal(gcp.Bytes(), &entry))
+	assert.Equal(t, "WARNING", entry["severity"])
+	assert.Equal(t, "slow query", entry["message"])
+	assert.Equal(t, "db", entry["logger"])
+	_, err = time.Parse(time.RFC3339Nano, entry["time"].(string))
+	assert.NoError(t, err)
+	assert.Contains(t, entry["caller"], "log/log_test.go")
+
+	_, err = Init(&Options{
+		Level: slog.LevelInfo,
+		Encoder: &EncoderOptions{
+			TimeKey:     "ts",
+			MessageKey:  "msg",
+			CallerKey:   OmitKey,
+			TimeFormat:  TimeEpochMillis,
+			LevelFormat: LevelUpper,
+		},
+		EnableCaller: true,
+		Sinks:        []Sink{{Format: FormatJSON, Writer: &custom}},
+	})
+	assert.NoError(t, err)
+	before := time.Now().UnixMilli()
+	slog.Info("epoch")
+
+	entry = nil
+	assert.NoError(t, json.Unmarshal(custom.Bytes(), &entry))
+	assert.Equal(t, "INFO", entry["level"])
+	assert.InDelta(t, float64(before), entry["ts"], 1000)
+	assert.NotContains(t, entry, "caller")
+}
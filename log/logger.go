@@ -1,6 +1,8 @@
 package log
 
 import (
+	"io"
+	stdlog "log"
 	"log/slog"
 	"sync"
 
@@ -9,35 +11,106 @@ import (
 
 var (
 	// globalAtomicLevel is the atomic level enabler for the global logger.
-	globalAtomicLevel zap.AtomicLevel
-	initOnce          sync.Once
+	// It is usable before Init so SetLevel never panics.
+	globalAtomicLevel = zap.NewAtomicLevel()
+
+	// mu guards current, the handler installed by the last Init.
+	mu      sync.Mutex
+	current *ZapHandler
+
+	// original is the default logger before the first Init, restored by Reset.
+	original = slog.Default()
+
+	// stdWriter and stdFlags are the output and flags of the standard log package
+	// before Init redirected it to the handler, restored with original.
+	stdWriter io.Writer
+	stdFlags  int
 )
 
-// Init initializes the global logger with the provided options.
-func Init(opts *Options) error {
-	var err error
-	initOnce.Do(func() {
-		if opts == nil {
-			opts = DefaultOptions()
-		}
+// Init initializes the global logger with the provided options. It may be called
+// again at runtime, e.g. when the output or format changes in config; the new
+// handler replaces the default logger, then the previous handler installed by Init
+// is flushed and its writers are closed. Init returns the previous default handler,
+// or an error without changing anything if a sink is misconfigured.
+//
+// Loggers derived from the previous handler, e.g. with With or Named, keep using it,
+// so derive them again after Init.
+func Init(opts *Options) (slog.Handler, error) {
+	if opts == nil {
+		opts = DefaultOptions()
+	}
+
+	if opts.FilePath != "" {
+		opts.Output = OutputFile
+	}
+	if err := opts.validate(); err != nil {
+		return nil, err
+	}
+
+	// Initialize the atomic level with the configured level
+	globalAtomicLevel.SetLevel(toZapLevel(opts.Level))
+
+	// Create the handler with the atomic level
+	handler := NewZapHandler(opts, globalAtomicLevel)
 
-		if opts.FilePath != "" {
-			opts.Output = OutputFile
+	mu.Lock()
+	defer mu.Unlock()
+
+	prev := slog.Default().Handler()
+	if current == nil {
+		stdWriter, stdFlags = stdlog.Writer(), stdlog.Flags()
+	}
+	slog.SetDefault(slog.New(handler))
+
+	old := current
+	current = handler
+	if old != nil {
+		if err := old.Close(); err != nil {
+			return prev, err
 		}
+	}
+	return prev, nil
+}
 
-		// Initialize the atomic level with the configured level
-		globalAtomicLevel = zap.NewAtomicLevelAt(toZapLevel(opts.Level))
+// Reset closes the handler installed by Init, restores the default logger and the
+// standard log package output from before Init, resets the global level to info,
+// removes all module levels and zeroes the sampling and OTLP stats.
+// It is meant for tests.
+func Reset() error {
+	mu.Lock()
+	defer mu.Unlock()
 
-		// Create the handler with the atomic level
-		handler := NewZapHandler(opts, globalAtomicLevel)
+	restoreDefault()
+	globalAtomicLevel.SetLevel(zap.InfoLevel)
+	moduleLevels.reset()
+	resetSamplingStats()
+	resetOTLPStats()
 
-		// Set the default slog logger
-		slog.SetDefault(slog.New(handler))
-	})
-	return err
+	old := current
+	current = nil
+	if old != nil {
+		return old.Close()
+	}
+	return nil
+}
+
+// restoreDefault puts back the default logger and the output of the standard log
+// package from before Init. The caller must hold mu.
+func restoreDefault() {
+	slog.SetDefault(original)
+	if stdWriter != nil {
+		stdlog.SetOutput(stdWriter)
+		stdlog.SetFlags(stdFlags)
+		stdWriter = nil
+	}
 }
 
 // SetLevel dynamically sets the log level.
 func SetLevel(l slog.Level) {
 	globalAtomicLevel.SetLevel(toZapLevel(l))
 }
+
+// GetLevel returns the current log level.
+func GetLevel() slog.Level {
+	return fromZapLevel(globalAtomicLevel.Level())
+}
@@ -0,0 +1,169 @@
+package log
+
+import (
+	"log/slog"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// moduleLevels holds the levels set for named loggers.
+var moduleLevels = &levelRegistry{levels: make(map[string]slog.Level), known: make(map[string]bool)}
+
+type levelRegistry struct {
+	mu     sync.RWMutex
+	levels map[string]slog.Level
+
+	// known records the modules passed to Named, so they can be listed.
+	known map[string]bool
+
+	// min is the lowest module level, so cores can let records through for modules
+	// more verbose than the global level; math.MaxInt64 if none is set.
+	min atomic.Int64
+}
+
+func init() {
+	moduleLevels.min.Store(math.MaxInt64)
+}
+
+// ModuleLevel describes the level of a module.
+type ModuleLevel struct {
+	Module string     `json:"module"`
+	Level  slog.Level `json:"level"`
+
+	// Explicit is false for modules inheriting the level of a parent module or the global level.
+	Explicit bool `json:"explicit"`
+}
+
+// SetModuleLevel sets the level of loggers created with Named(module),
+// overriding the global level for them. Modules below it, e.g. "db.pool" for "db",
+// inherit the level unless they have their own.
+func SetModuleLevel(module string, l slog.Level) {
+	moduleLevels.mu.Lock()
+	defer moduleLevels.mu.Unlock()
+	moduleLevels.levels[module] = l
+	moduleLevels.updateMin()
+}
+
+// UnsetModuleLevel removes the level of module, so its loggers follow the global level again.
+func UnsetModuleLevel(module string) {
+	moduleLevels.mu.Lock()
+	defer moduleLevels.mu.Unlock()
+	delete(moduleLevels.levels, module)
+	moduleLevels.updateMin()
+}
+
+// GetModuleLevel returns the level set for module itself, if any.
+func GetModuleLevel(module string) (slog.Level, bool) {
+	moduleLevels.mu.RLock()
+	defer moduleLevels.mu.RUnlock()
+	l, ok := moduleLevels.levels[module]
+	return l, ok
+}
+
+// EffectiveLevel returns the level loggers of module use: its own level, else the level of
+// the closest parent module ("db" for "db.pool"), else the global level.
+func EffectiveLevel(module string) slog.Level {
+	if l, ok := moduleLevels.level(module); ok {
+		return l
+	}
+	return GetLevel()
+}
+
+// ModuleLevels lists the modules created with Named or given a level, sorted by name,
+// with their effective levels.
+func ModuleLevels() []ModuleLevel {
+	moduleLevels.mu.RLock()
+	names := make([]string, 0, len(moduleLevels.known)+len(moduleLevels.levels))
+	for module := range moduleLevels.known {
+		names = append(names, module)
+	}
+	for module := range moduleLevels.levels {
+		if !moduleLevels.known[module] {
+			names = append(names, module)
+		}
+	}
+	moduleLevels.mu.RUnlock()
+	sort.Strings(names)
+
+	out := make([]ModuleLevel, 0, len(names))
+	for _, module := range names {
+		_, explicit := GetModuleLevel(module)
+		out = append(out, ModuleLevel{Module: module, Level: EffectiveLevel(module), Explicit: explicit})
+	}
+	return out
+}
+
+// updateMin recomputes min. The caller must hold mu.
+func (r *levelRegistry) updateMin() {
+	min := int64(math.MaxInt64)
+	for _, l := range r.levels {
+		if int64(l) < min {
+			min = int64(l)
+		}
+	}
+	r.min.Store(min)
+}
+
+// level returns the level set for module or its closest parent module.
+func (r *levelRegistry) level(module string) (slog.Level, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for {
+		if l, ok := r.levels[module]; ok {
+			return l, true
+		}
+		i := strings.LastIndexByte(module, '.')
+		if i < 0 {
+			return 0, false
+		}
+		module = module[:i]
+	}
+}
+
+// reset removes all module levels and known modules.
+func (r *levelRegistry) reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.levels = make(map[string]slog.Level)
+	r.known = make(map[string]bool)
+	r.updateMin()
+}
+
+func (r *levelRegistry) register(module string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.known[module] = true
+}
+
+// anyEnabled reports whether some module logs at l.
+func (r *levelRegistry) anyEnabled(l slog.Level) bool {
+	return int64(l) >= r.min.Load()
+}
+
+// moduleEnabler enables a level if the global level or any module level allows it.
+// ZapHandler.Enabled then applies the level of the handler's own module.
+type moduleEnabler struct {
+	global zapcore.LevelEnabler
+}
+
+func (e moduleEnabler) Enabled(l zapcore.Level) bool {
+	return e.global.Enabled(l) || moduleLevels.anyEnabled(fromZapLevel(l))
+}
+
+// Named returns a logger for module whose level can be changed with SetModuleLevel.
+// Use dotted names such as "db.pool" for modules that inherit the level of "db".
+// Records carry the module name in the "logger" field. Call it after Init, as the
+// logger is derived from the default handler at the time of the call.
+func Named(module string) *slog.Logger {
+	moduleLevels.register(module)
+	h := slog.Default().Handler()
+	if zh, ok := h.(*ZapHandler); ok {
+		return slog.New(zh.named(module))
+	}
+	return slog.New(h).With("logger", module)
+}
@@ -1,7 +1,10 @@
 package log
 
 import (
+	"fmt"
+	"io"
 	"log/slog"
+	"time"
 )
 
 type Format string
@@ -17,6 +20,9 @@ const (
 	OutputStdout Output = "stdout"
 	OutputStderr Output = "stderr"
 	OutputFile   Output = "file"
+
+	// OutputOTLP exports records to an OpenTelemetry collector over OTLP/HTTP with JSON encoding.
+	OutputOTLP Output = "otlp"
 )
 
 type Options struct {
@@ -43,6 +49,80 @@ type Options struct {
 
 	// EnableStack enables stack trace recording.
 	EnableStack bool
+
+	// Sinks lists several outputs to write to at once. If set, Format, Output and
+	// FilePath are ignored; the rotation settings apply to file sinks.
+	Sinks []Sink
+
+	// Sampling limits repeated entries; nil logs everything.
+	Sampling *SamplingOptions
+
+	// OTelTrace adds trace_id and span_id from the OpenTelemetry span context in the
+	// context of each record. They take precedence over WithTraceID.
+	OTelTrace bool
+
+	// ServiceName is exported as the service.name resource attribute by OTLP sinks.
+	ServiceName string
+
+	// Encoder sets the field names and time, level and caller formats; nil keeps the defaults.
+	// See ECSEncoderOptions and GCPEncoderOptions.
+	Encoder *EncoderOptions
+
+	// Redact masks sensitive keys and values before records are encoded; nil disables it.
+	// See DefaultRedactOptions.
+	Redact *RedactOptions
+
+	// Buffer buffers writes to every sink and flushes them in the background;
+	// nil writes synchronously. Call Sync or Close before exiting.
+	Buffer *BufferOptions
+}
+
+// BufferOptions configures buffered writing. OTLP sinks always export in batches.
+type BufferOptions struct {
+	// Size is the buffer size in bytes per sink; defaults to 256 kB.
+	Size int
+
+	// FlushInterval is how often the buffer is flushed; defaults to one second.
+	FlushInterval time.Duration
+}
+
+// SamplingOptions configures sampling of entries with the same message and level:
+// within each Tick the first First entries are logged, then every Thereafter-th.
+// Counts of logged and dropped entries are available from GetSamplingStats.
+type SamplingOptions struct {
+	// Tick is the sampling interval; defaults to one second.
+	Tick time.Duration
+
+	// First is the number of entries logged per Tick before sampling starts.
+	First int
+
+	// Thereafter logs every Thereafter-th entry after First; 0 drops them all.
+	Thereafter int
+}
+
+// Sink is one log output with its own format and level threshold.
+type Sink struct {
+	// Format specifies the output format (json or text).
+	Format Format
+
+	// Output specifies where to write logs (stdout, stderr, or file).
+	Output Output
+
+	// FilePath is the path to the log file (required if Output is "file").
+	FilePath string
+
+	// Endpoint is the OTLP/HTTP logs URL for OutputOTLP, e.g. http://localhost:4318/v1/logs.
+	Endpoint string
+
+	// Headers are added to OTLP export requests, e.g. for authentication.
+	Headers map[string]string
+
+	// Writer is written to instead of Output if set. It is not closed by the handler.
+	Writer io.Writer
+
+	// Level is the minimum level written to this sink, on top of the global and
+	// module levels; nil writes everything they allow, e.g. slog.LevelWarn.
+	Level slog.Leveler
 }
 
 func DefaultOptions() *Options {
@@ -58,3 +138,21 @@ func DefaultOptions() *Options {
 		Compress:     true,
 	}
 }
+
+// sinks returns opts.Sinks, or the sink described by Format, Output and FilePath if there are none.
+func (opts *Options) sinks() []Sink {
+	if len(opts.Sinks) == 0 {
+		return []Sink{{Format: opts.Format, Output: opts.Output, FilePath: opts.FilePath}}
+	}
+	return opts.Sinks
+}
+
+// validate reports sinks that cannot work.
+func (opts *Options) validate() error {
+	for i, sink := range opts.sinks() {
+		if sink.Output == OutputOTLP && sink.Writer == nil && sink.Endpoint == "" {
+			return fmt.Errorf("sink %d: OTLP output requires an endpoint", i)
+		}
+	}
+	return nil
+}
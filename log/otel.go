@@ -0,0 +1,28 @@
+package log
+
+import (
+	"context"
+	"log/slog"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// withOTelTrace adds trace_id and span_id from the span context in ctx to fields,
+// replacing a trace_id set with WithTraceID.
+func withOTelTrace(ctx context.Context, fields []slog.Attr) []slog.Attr {
+	sc := trace.SpanContextFromContext(ctx)
+	if !sc.IsValid() {
+		return fields
+	}
+
+	out := make([]slog.Attr, 0, len(fields)+2)
+	for _, f := range fields {
+		if f.Key != string(TraceIDKey) {
+			out = append(out, f)
+		}
+	}
+	return append(out,
+		slog.String(string(TraceIDKey), sc.TraceID().String()),
+		slog.String("span_id", sc.SpanID().String()),
+	)
+}
@@ -0,0 +1,468 @@
+package log
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+)
+
+const (
+	otlpScope = "github.com/fuguiw/fg-lib/log"
+
+	defaultOTLPBatchSize = 100
+	defaultOTLPInterval  = time.Second
+	defaultOTLPTimeout   = 10 * time.Second
+)
+
+// otlpCore is a zapcore.Core exporting entries as OTLP log records.
+type otlpCore struct {
+	zapcore.LevelEnabler
+	exp    *otlpExporter
+	fields []zapcore.Field
+}
+
+func (c *otlpCore) With(fields []zapcore.Field) zapcore.Core {
+	clone := *c
+	clone.fields = append(append([]zapcore.Field(nil), c.fields...), fields...)
+	return &clone
+}
+
+func (c *otlpCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+func (c *otlpCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	enc := zapcore.NewMapObjectEncoder()
+	for _, f := range c.fields {
+		f.AddTo(enc)
+	}
+	for _, f := range fields {
+		f.AddTo(enc)
+	}
+	c.exp.add(newOTLPRecord(ent, enc.Fields))
+	return nil
+}
+
+func (c *otlpCore) Sync() error {
+	return c.exp.flush()
+}
+
+// otlpStats counts OTLP exports across all handlers since start or Reset.
+var otlpStats struct {
+	exported atomic.Uint64
+	dropped  atomic.Uint64
+}
+
+// OTLPStats holds the counts of records exported to and dropped for OTLP collectors.
+type OTLPStats struct {
+	Exported uint64 `json:"exported"`
+	Dropped  uint64 `json:"dropped"`
+}
+
+// GetOTLPStats returns the counts of exported and dropped OTLP records since start or
+// Reset. Records are dropped when an export fails, e.g. as the collector is down.
+// They only grow, so they can be exported as counters and alerted on.
+func GetOTLPStats() OTLPStats {
+	return OTLPStats{
+		Exported: otlpStats.exported.Load(),
+		Dropped:  otlpStats.dropped.Load(),
+	}
+}
+
+func resetOTLPStats() {
+	otlpStats.exported.Store(0)
+	otlpStats.dropped.Store(0)
+}
+
+// otlpExporter batches records and posts them to an OTLP/HTTP logs endpoint as JSON.
+type otlpExporter struct {
+	endpoint string
+	headers  map[string]string
+	resource otlpResource
+	client   *http.Client
+
+	// ctx is cancelled to abandon exports when closing times out.
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	batchSize int
+	interval  time.Duration
+
+	// mu guards records and the state of the background loop, which only runs while
+	// records are pending, so an exporter that is never closed leaves no goroutine behind.
+	mu      sync.Mutex
+	records []otlpRecord
+	running bool
+	closed  bool
+	loops   sync.WaitGroup
+
+	// sendMu serializes posts, so batches arrive in order.
+	sendMu sync.Mutex
+
+	full chan struct{}
+	stop chan struct{}
+	once sync.Once
+}
+
+func newOTLPExporter(sink Sink, serviceName string) *otlpExporter {
+	ctx, cancel := context.WithCancel(context.Background())
+	e := &otlpExporter{
+		ctx:       ctx,
+		cancel:    cancel,
+		endpoint:  sink.Endpoint,
+		headers:   sink.Headers,
+		client:    &http.Client{Timeout: defaultOTLPTimeout},
+		batchSize: defaultOTLPBatchSize,
+		interval:  defaultOTLPInterval,
+		full:      make(chan struct{}, 1),
+		stop:      make(chan struct{}),
+	}
+	if serviceName != "" {
+		e.resource.Attributes = []otlpKeyValue{{Key: "service.name", Value: otlpString(serviceName)}}
+	}
+	return e
+}
+
+func (e *otlpExporter) add(r otlpRecord) {
+	e.mu.Lock()
+	e.records = append(e.records, r)
+	full := len(e.records) >= e.batchSize
+	closed := e.closed
+	if !closed && !e.running {
+		e.running = true
+		e.loops.Add(1)
+		go e.loop()
+	}
+	e.mu.Unlock()
+
+	switch {
+	case closed:
+		// Loggers derived from a closed handler export without batching
+		e.report(e.flush())
+	case full:
+		select {
+		case e.full <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// loop flushes in the background so logging never waits on the network. It exits
+// once nothing is pending; add starts it again.
+func (e *otlpExporter) loop() {
+	defer e.loops.Done()
+	ticker := time.NewTicker(e.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-e.full:
+		case <-e.stop:
+			return
+		}
+		e.report(e.flush())
+
+		e.mu.Lock()
+		if len(e.records) == 0 {
+			e.running = false
+			e.mu.Unlock()
+			return
+		}
+		e.mu.Unlock()
+	}
+}
+
+func (e *otlpExporter) report(err error) {
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "log: OTLP export failed: %v\n", err)
+	}
+}
+
+// flush posts the pending records; they are dropped and counted if the post fails.
+func (e *otlpExporter) flush() error {
+	e.sendMu.Lock()
+	defer e.sendMu.Unlock()
+
+	e.mu.Lock()
+	records := e.records
+	e.records = nil
+	e.mu.Unlock()
+	if len(records) == 0 {
+		return nil
+	}
+
+	if err := e.post(records); err != nil {
+		otlpStats.dropped.Add(uint64(len(records)))
+		return err
+	}
+	otlpStats.exported.Add(uint64(len(records)))
+	return nil
+}
+
+func (e *otlpExporter) post(records []otlpRecord) error {
+	body, err := json.Marshal(otlpRequest{ResourceLogs: []otlpResourceLogs{{
+		Resource:  e.resource,
+		ScopeLogs: []otlpScopeLogs{{Scope: otlpScopeInfo{Name: otlpScope}, LogRecords: records}},
+	}}})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(e.ctx, http.MethodPost, e.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range e.headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("%s: unexpected status %s", e.endpoint, resp.Status)
+	}
+	return nil
+}
+
+// Close stops the background flushing and exports what is left.
+func (e *otlpExporter) Close() error {
+	return e.closeContext(context.Background())
+}
+
+// closeContext is Close, cancelling the exports when ctx is done.
+func (e *otlpExporter) closeContext(ctx context.Context) error {
+	stop := context.AfterFunc(ctx, e.cancel)
+	defer stop()
+
+	e.once.Do(func() {
+		e.mu.Lock()
+		e.closed = true
+		e.mu.Unlock()
+		close(e.stop)
+	})
+	e.loops.Wait()
+	return e.flush()
+}
+
+// OTLP/HTTP JSON encoding of ExportLogsServiceRequest.
+type (
+	otlpRequest struct {
+		ResourceLogs []otlpResourceLogs `json:"resourceLogs"`
+	}
+	otlpResourceLogs struct {
+		Resource  otlpResource    `json:"resource"`
+		ScopeLogs []otlpScopeLogs `json:"scopeLogs"`
+	}
+	otlpResource struct {
+		Attributes []otlpKeyValue `json:"attributes,omitempty"`
+	}
+	otlpScopeLogs struct {
+		Scope      otlpScopeInfo `json:"scope"`
+		LogRecords []otlpRecord  `json:"logRecords"`
+	}
+	otlpScopeInfo struct {
+		Name string `json:"name"`
+	}
+	otlpRecord struct {
+		TimeUnixNano         string         `json:"timeUnixNano"`
+		ObservedTimeUnixNano string         `json:"observedTimeUnixNano"`
+		SeverityNumber       int            `json:"severityNumber"`
+		SeverityText         string         `json:"severityText"`
+		Body                 otlpAnyValue   `json:"body"`
+		Attributes           []otlpKeyValue `json:"attributes,omitempty"`
+		TraceID              string         `json:"traceId,omitempty"`
+		SpanID               string         `json:"spanId,omitempty"`
+	}
+	otlpKeyValue struct {
+		Key   string       `json:"key"`
+		Value otlpAnyValue `json:"value"`
+	}
+	otlpAnyValue struct {
+		StringValue *string        `json:"stringValue,omitempty"`
+		BoolValue   *bool          `json:"boolValue,omitempty"`
+		IntValue    *string        `json:"intValue,omitempty"`
+		DoubleValue *float64       `json:"doubleValue,omitempty"`
+		ArrayValue  *otlpArray     `json:"arrayValue,omitempty"`
+		KvlistValue *otlpKeyValues `json:"kvlistValue,omitempty"`
+	}
+	otlpArray struct {
+		Values []otlpAnyValue `json:"values"`
+	}
+	otlpKeyValues struct {
+		Values []otlpKeyValue `json:"values"`
+	}
+)
+
+func newOTLPRecord(ent zapcore.Entry, fields map[string]interface{}) otlpRecord {
+	r := otlpRecord{
+		TimeUnixNano:         strconv.FormatInt(ent.Time.UnixNano(), 10),
+		ObservedTimeUnixNano: strconv.FormatInt(time.Now().UnixNano(), 10),
+		SeverityNumber:       otlpSeverity(ent.Level),
+		SeverityText:         ent.Level.CapitalString(),
+		Body:                 otlpString(ent.Message),
+	}
+
+	// Correlation IDs are record fields rather than attributes
+	if id, ok := fields[string(TraceIDKey)].(string); ok && isHexID(id, 32) {
+		r.TraceID = id
+		delete(fields, string(TraceIDKey))
+	}
+	if id, ok := fields["span_id"].(string); ok && isHexID(id, 16) {
+		r.SpanID = id
+		delete(fields, "span_id")
+	}
+
+	r.Attributes = otlpAttributes(fields)
+	if ent.LoggerName != "" {
+		r.Attributes = append(r.Attributes, otlpKeyValue{Key: "logger", Value: otlpString(ent.LoggerName)})
+	}
+	if ent.Caller.Defined {
+		r.Attributes = append(r.Attributes,
+			otlpKeyValue{Key: "code.filepath", Value: otlpString(ent.Caller.File)},
+			otlpKeyValue{Key: "code.lineno", Value: otlpInt(int64(ent.Caller.Line))},
+			otlpKeyValue{Key: "code.function", Value: otlpString(ent.Caller.Function)},
+		)
+	}
+	if ent.Stack != "" {
+		r.Attributes = append(r.Attributes, otlpKeyValue{Key: "exception.stacktrace", Value: otlpString(ent.Stack)})
+	}
+	return r
+}
+
+// otlpSeverity maps zap levels to OTLP severity numbers.
+func otlpSeverity(l zapcore.Level) int {
+	switch l {
+	case zapcore.DebugLevel:
+		return 5
+	case zapcore.InfoLevel:
+		return 9
+	case zapcore.WarnLevel:
+		return 13
+	case zapcore.ErrorLevel:
+		return 17
+	case zapcore.DPanicLevel, zapcore.PanicLevel, zapcore.FatalLevel:
+		return 21
+	default:
+		return 0 // SEVERITY_NUMBER_UNSPECIFIED
+	}
+}
+
+// otlpAttributes converts fields to attributes sorted by key.
+func otlpAttributes(fields map[string]interface{}) []otlpKeyValue {
+	keys := make([]string, 0, len(fields))
+	for k := range fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	attrs := make([]otlpKeyValue, 0, len(keys))
+	for _, k := range keys {
+		attrs = append(attrs, otlpKeyValue{Key: k, Value: otlpValue(fields[k])})
+	}
+	return attrs
+}
+
+func otlpValue(v interface{}) otlpAnyValue {
+	switch v := v.(type) {
+	case string:
+		return otlpString(v)
+	case bool:
+		return otlpAnyValue{BoolValue: &v}
+	case int:
+		return otlpInt(int64(v))
+	case int8:
+		return otlpInt(int64(v))
+	case int16:
+		return otlpInt(int64(v))
+	case int32:
+		return otlpInt(int64(v))
+	case int64:
+		return otlpInt(v)
+	case uint:
+		return otlpUint(uint64(v))
+	case uint8:
+		return otlpInt(int64(v))
+	case uint16:
+		return otlpInt(int64(v))
+	case uint32:
+		return otlpInt(int64(v))
+	case uint64:
+		return otlpUint(v)
+	case float32:
+		return otlpDouble(float64(v))
+	case float64:
+		return otlpDouble(v)
+	case time.Time:
+		return otlpString(v.Format(time.RFC3339Nano))
+	case time.Duration:
+		return otlpString(v.String())
+	case map[string]interface{}:
+		return otlpAnyValue{KvlistValue: &otlpKeyValues{Values: otlpAttributes(v)}}
+	case []interface{}:
+		values := make([]otlpAnyValue, 0, len(v))
+		for _, item := range v {
+			values = append(values, otlpValue(item))
+		}
+		return otlpAnyValue{ArrayValue: &otlpArray{Values: values}}
+	case nil:
+		return otlpAnyValue{}
+	case error:
+		return otlpString(v.Error())
+	case fmt.Stringer:
+		return otlpString(v.String())
+	default:
+		return otlpString(fmt.Sprint(v))
+	}
+}
+
+func otlpString(s string) otlpAnyValue {
+	return otlpAnyValue{StringValue: &s}
+}
+
+// otlpInt encodes an int64 as a string, as the protobuf JSON mapping requires.
+func otlpInt(n int64) otlpAnyValue {
+	s := strconv.FormatInt(n, 10)
+	return otlpAnyValue{IntValue: &s}
+}
+
+func otlpUint(n uint64) otlpAnyValue {
+	if n > math.MaxInt64 {
+		return otlpString(strconv.FormatUint(n, 10))
+	}
+	return otlpInt(int64(n))
+}
+
+// otlpDouble encodes non-finite numbers as strings, which JSON cannot represent.
+func otlpDouble(f float64) otlpAnyValue {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return otlpString(strconv.FormatFloat(f, 'g', -1, 64))
+	}
+	return otlpAnyValue{DoubleValue: &f}
+}
+
+func isHexID(s string, n int) bool {
+	if len(s) != n {
+		return false
+	}
+	_, err := hex.DecodeString(s)
+	return err == nil
+}
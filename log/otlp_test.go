@@ -0,0 +1,193 @@
+package log
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// collector stands in for an OpenTelemetry collector's OTLP/HTTP logs endpoint.
+type collector struct {
+	mu       sync.Mutex
+	requests []otlpRequest
+	headers  []http.Header
+}
+
+func (c *collector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var req otlpRequest
+	if r.URL.Path != "/v1/logs" || json.NewDecoder(r.Body).Decode(&req) != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+	c.mu.Lock()
+	c.requests = append(c.requests, req)
+	c.headers = append(c.headers, r.Header)
+	c.mu.Unlock()
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write([]byte("{}"))
+}
+
+func (c *collector) records() []otlpRecord {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var out []otlpRecord
+	for _, req := range c.requests {
+		for _, rl := range req.ResourceLogs {
+			for _, sl := range rl.ScopeLogs {
+				out = append(out, sl.LogRecords...)
+			}
+		}
+	}
+	return out
+}
+
+func attr(r otlpRecord, key string) *otlpAnyValue {
+	for _, kv := range r.Attributes {
+		if kv.Key == key {
+			return &kv.Value
+		}
+	}
+	return nil
+}
+
+func TestOTLPExport(t *testing.T) {
+	assert.NoError(t, Reset())
+	defer Reset()
+	c := &collector{}
+	srv := httptest.NewServer(c)
+	defer srv.Close()
+
+	var text bytes.Buffer
+	_, err := Init(&Options{
+		Level:        slog.LevelInfo,
+		OTelTrace:    true,
+		ServiceName:  "checkout",
+		EnableCaller: true,
+		Sinks: []Sink{
+			{Output: OutputOTLP, Endpoint: srv.URL + "/v1/logs", Headers: map[string]string{"Authorization": "Bearer t"}},
+			{Format: FormatJSON, Writer: &text},
+		},
+	})
+	assert.NoError(t, err)
+
+	traceID, _ := trace.TraceIDFromHex("4bf92f3577b34da6a3ce929d0e0e4736")
+	spanID, _ := trace.SpanIDFromHex("00f067aa0ba902b7")
+	ctx := trace.ContextWithSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID: traceID, SpanID: spanID, TraceFlags: trace.FlagsSampled,
+	}))
+	ctx = WithTraceID(ctx, "manual")
+
+	slog.WarnContext(ctx, "payment declined", "amount", 42, slog.Group("card", "brand", "visa"))
+	slog.Info("no span")
+	assert.NoError(t, Sync())
+
+	records := c.records()
+	if assert.Len(t, records, 2) {
+		r := records[0]
+		assert.Equal(t, "payment declined", *r.Body.StringValue)
+		assert.Equal(t, 13, r.SeverityNumber)
+		assert.Equal(t, "WARN", r.SeverityText)
+		assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", r.TraceID)
+		assert.Equal(t, "00f067aa0ba902b7", r.SpanID)
+		assert.Nil(t, attr(r, "trace_id"))
+		if amount := attr(r, "amount"); assert.NotNil(t, amount) {
+			assert.Equal(t, "42", *amount.IntValue)
+		}
+		if card := attr(r, "card"); assert.NotNil(t, card) && assert.NotNil(t, card.KvlistValue) {
+			assert.Equal(t, "brand", card.KvlistValue.Values[0].Key)
+		}
+		assert.NotNil(t, attr(r, "code.lineno"))
+		assert.Empty(t, records[1].TraceID)
+	}
+
+	c.mu.Lock()
+	assert.Equal(t, "Bearer t", c.headers[0].Get("Authorization"))
+	assert.Equal(t, "checkout", *c.requests[0].ResourceLogs[0].Resource.Attributes[0].Value.StringValue)
+	c.mu.Unlock()
+
+	// The other sinks get the span IDs as fields.
+	assert.Contains(t, text.String(), `"trace_id":"4bf92f3577b34da6a3ce929d0e0e4736"`)
+	assert.Contains(t, text.String(), `"span_id":"00f067aa0ba902b7"`)
+	assert.NotContains(t, text.String(), "manual")
+
+	// Close exports what is left.
+	slog.Error("on close")
+	assert.NoError(t, Close())
+	assert.Len(t, c.records(), 3)
+}
+
+func TestOTLPEndpointRequired(t *testing.T) {
+	assert.NoError(t, Reset())
+	defer Reset()
+	_, err := Init(&Options{Level: slog.LevelInfo, Sinks: []Sink{{Output: OutputOTLP}}})
+	assert.ErrorContains(t, err, "requires an endpoint")
+	_, err = Init(&Options{Level: slog.LevelInfo, Output: OutputOTLP})
+	assert.ErrorContains(t, err, "requires an endpoint")
+	assert.Equal(t, original, slog.Default())
+}
+
+func TestOTLPStats(t *testing.T) {
+	assert.NoError(t, Reset())
+	defer Reset()
+	var down atomic.Bool
+	c := &collector{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if down.Load() {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		c.ServeHTTP(w, r)
+	}))
+	defer srv.Close()
+
+	_, err := Init(&Options{Level: slog.LevelInfo, Sinks: []Sink{{Output: OutputOTLP, Endpoint: srv.URL + "/v1/logs"}}})
+	assert.NoError(t, err)
+
+	slog.Info("exported")
+	assert.NoError(t, Sync())
+	down.Store(true)
+	slog.Info("dropped 1")
+	slog.Info("dropped 2")
+	assert.Error(t, Sync())
+
+	assert.Equal(t, OTLPStats{Exported: 1, Dropped: 2}, GetOTLPStats())
+	assert.NoError(t, Reset())
+	assert.Equal(t, OTLPStats{}, GetOTLPStats())
+}
+
+func TestOTLPExporter_IdleLoop(t *testing.T) {
+	c := &collector{}
+	srv := httptest.NewServer(c)
+	defer srv.Close()
+
+	// Never closed, as with a handler from NewZapHandler that is dropped
+	e := newOTLPExporter(Sink{Endpoint: srv.URL + "/v1/logs"}, "")
+	e.interval = 10 * time.Millisecond
+	running := func() bool {
+		e.mu.Lock()
+		defer e.mu.Unlock()
+		return e.running
+	}
+	assert.False(t, running(), "no loop before the first record")
+
+	e.add(otlpRecord{Body: otlpString("hello")})
+	assert.True(t, running())
+	assert.Eventually(t, func() bool { return !running() }, time.Second, 5*time.Millisecond)
+	assert.Len(t, c.records(), 1)
+
+	// Records added after Close are exported right away
+	assert.NoError(t, e.Close())
+	e.add(otlpRecord{Body: otlpString("late")})
+	assert.False(t, running())
+	assert.Len(t, c.records(), 2)
+}
@@ -0,0 +1,382 @@
+package log
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"reflect"
+	"regexp"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// DefaultRedactMask replaces redacted values.
+const DefaultRedactMask = "[REDACTED]"
+
+// Sensitive is implemented by types whose values must never be logged, such as
+// credentials. With redaction enabled they are replaced by the mask, whether
+// logged directly or returned by a slog.LogValuer.
+type Sensitive interface {
+	Sensitive() bool
+}
+
+// RedactOptions configures masking of sensitive data before records are encoded.
+type RedactOptions struct {
+	// Keys are matched case-insensitively against attribute keys, including keys in
+	// groups and in logged maps and structs. Keys are split into words at '_', '-', '.'
+	// and camelCase boundaries; an attribute whose key contains the words of one of
+	// them in order is masked entirely, so "token" matches "access_token" but not "max_tokens".
+	Keys []string
+
+	// Patterns are matched against string values, messages and errors; matches are
+	// masked. Matches of CreditCardPattern are only masked if they pass the Luhn check.
+	Patterns []*regexp.Regexp
+
+	// Mask replaces redacted data; defaults to DefaultRedactMask.
+	Mask string
+}
+
+// Patterns for common sensitive values.
+var (
+	CreditCardPattern = regexp.MustCompile(`\b(?:\d[ -]?){12,18}\d\b`)
+	EmailPattern      = regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`)
+	JWTPattern        = regexp.MustCompile(`\beyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+`)
+)
+
+// DefaultRedactOptions masks common credential keys, credit card numbers, emails and JWTs.
+func DefaultRedactOptions() *RedactOptions {
+	return &RedactOptions{
+		Keys:     []string{"password", "passwd", "secret", "token", "authorization", "api_key", "apikey", "cookie"},
+		Patterns: []*regexp.Regexp{CreditCardPattern, EmailPattern, JWTPattern},
+		Mask:     DefaultRedactMask,
+	}
+}
+
+type redactor struct {
+	keys     [][]string
+	patterns []*regexp.Regexp
+	mask     string
+}
+
+func newRedactor(opts *RedactOptions) *redactor {
+	r := &redactor{patterns: opts.Patterns, mask: opts.Mask}
+	if r.mask == "" {
+		r.mask = DefaultRedactMask
+	}
+	for _, k := range opts.Keys {
+		r.keys = append(r.keys, keyWords(k))
+	}
+	return r
+}
+
+// record returns a copy of rec with its message and attributes redacted.
+func (r *redactor) record(rec slog.Record) slog.Record {
+	out := slog.NewRecord(rec.Time, rec.Level, r.string(rec.Message), rec.PC)
+	rec.Attrs(func(a slog.Attr) bool {
+		out.AddAttrs(r.attr(a))
+		return true
+	})
+	return out
+}
+
+func (r *redactor) attrs(attrs []slog.Attr) []slog.Attr {
+	out := make([]slog.Attr, len(attrs))
+	for i, a := range attrs {
+		out[i] = r.attr(a)
+	}
+	return out
+}
+
+func (r *redactor) attr(a slog.Attr) slog.Attr {
+	if r.sensitiveKey(a.Key) || isSensitive(a.Value) {
+		return slog.String(a.Key, r.mask)
+	}
+
+	v := a.Value.Resolve()
+	if isSensitive(v) {
+		return slog.String(a.Key, r.mask)
+	}
+	switch v.Kind() {
+	case slog.KindString:
+		return slog.String(a.Key, r.string(v.String()))
+	case slog.KindGroup:
+		return slog.Attr{Key: a.Key, Value: slog.GroupValue(r.attrs(v.Group())...)}
+	case slog.KindAny:
+		return slog.Attr{Key: a.Key, Value: r.anyValue(v.Any())}
+	default:
+		return slog.Attr{Key: a.Key, Value: v}
+	}
+}
+
+// maxRedactDepth bounds the walk of logged values, which may contain cycles.
+const maxRedactDepth = 32
+
+// anyValue redacts a value logged with slog.Any. Errors and fmt.Stringers are
+// redacted as their text. Maps, structs and slices are walked into the shape
+// encoding/json gives them, masking Sensitive values and sensitive keys on the way.
+func (r *redactor) anyValue(v interface{}) slog.Value {
+	switch v := v.(type) {
+	case nil, time.Time, []byte:
+		return slog.AnyValue(v)
+	case error:
+		return slog.StringValue(r.string(v.Error()))
+	case fmt.Stringer:
+		return slog.StringValue(r.string(v.String()))
+	}
+
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr && !rv.IsNil() {
+		rv = rv.Elem()
+	}
+	switch rv.Kind() {
+	case reflect.Map, reflect.Struct, reflect.Slice, reflect.Array:
+		return slog.AnyValue(r.walk(reflect.ValueOf(v), 0))
+	default:
+		return slog.AnyValue(v)
+	}
+}
+
+// walk converts v to maps, slices and scalars as encoding/json would encode it,
+// with Sensitive values, values under sensitive keys and pattern matches masked.
+func (r *redactor) walk(v reflect.Value, depth int) interface{} {
+	if !v.IsValid() {
+		return nil
+	}
+	if depth > maxRedactDepth || !v.CanInterface() {
+		return r.mask
+	}
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface, reflect.Map, reflect.Slice:
+		if v.IsNil() {
+			return nil
+		}
+	}
+
+	switch x := v.Interface().(type) {
+	case Sensitive:
+		if x.Sensitive() {
+			return r.mask
+		}
+	case error:
+		return r.string(x.Error())
+	case json.Marshaler, encoding.TextMarshaler:
+		return r.marshaled(x)
+	}
+
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		return r.walk(v.Elem(), depth+1)
+	case reflect.String:
+		return r.string(v.String())
+	case reflect.Struct:
+		out := make(map[string]interface{})
+		r.walkFields(v, out, depth)
+		return out
+	case reflect.Map:
+		out := make(map[string]interface{}, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			k := fmt.Sprint(iter.Key().Interface())
+			if r.sensitiveKey(k) {
+				out[k] = r.mask
+			} else {
+				out[k] = r.walk(iter.Value(), depth+1)
+			}
+		}
+		return out
+	case reflect.Slice, reflect.Array:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			return r.marshaled(v.Interface())
+		}
+		out := make([]interface{}, v.Len())
+		for i := range out {
+			out[i] = r.walk(v.Index(i), depth+1)
+		}
+		return out
+	case reflect.Func, reflect.Chan, reflect.UnsafePointer, reflect.Complex64, reflect.Complex128:
+		// Not encodable, so not logged
+		return r.mask
+	default:
+		return v.Interface()
+	}
+}
+
+// walkFields adds the exported fields of struct v to out under their JSON names,
+// flattening embedded structs as encoding/json does.
+func (r *redactor) walkFields(v reflect.Value, out map[string]interface{}, depth int) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		fv := v.Field(i)
+		if f.Anonymous && name == "" {
+			embedded := fv
+			if embedded.Kind() == reflect.Ptr {
+				if embedded.IsNil() {
+					continue
+				}
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() == reflect.Struct {
+				r.walkFields(embedded, out, depth+1)
+				continue
+			}
+		}
+		if name == "" {
+			name = f.Name
+		}
+		if strings.Contains(","+opts+",", ",omitempty,") && fv.IsZero() {
+			continue
+		}
+		if r.sensitiveKey(name) {
+			out[name] = r.mask
+		} else {
+			out[name] = r.walk(fv, depth+1)
+		}
+	}
+}
+
+// marshaled redacts a value with its own JSON or text encoding in its JSON form.
+func (r *redactor) marshaled(v interface{}) interface{} {
+	data, err := json.Marshal(v)
+	if err != nil {
+		// Cannot be inspected, so cannot be logged safely
+		return r.mask
+	}
+	var doc interface{}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(&doc); err != nil {
+		return r.mask
+	}
+	return r.document(doc)
+}
+
+// document redacts a decoded JSON document in place.
+func (r *redactor) document(doc interface{}) interface{} {
+	switch doc := doc.(type) {
+	case map[string]interface{}:
+		for k, v := range doc {
+			if r.sensitiveKey(k) {
+				doc[k] = r.mask
+			} else {
+				doc[k] = r.document(v)
+			}
+		}
+	case []interface{}:
+		for i, v := range doc {
+			doc[i] = r.document(v)
+		}
+	case string:
+		return r.string(doc)
+	}
+	return doc
+}
+
+func (r *redactor) sensitiveKey(key string) bool {
+	words := keyWords(key)
+	for _, k := range r.keys {
+		if containsWords(words, k) {
+			return true
+		}
+	}
+	return false
+}
+
+// keyWords splits a key into lower-case words at '_', '-', '.', spaces and camelCase
+// boundaries, e.g. "X-Api-Key" into [x api key] and "dbPassword" into [db password].
+func keyWords(key string) []string {
+	var words []string
+	var word []rune
+	flush := func() {
+		if len(word) > 0 {
+			words = append(words, strings.ToLower(string(word)))
+			word = word[:0]
+		}
+	}
+	runes := []rune(key)
+	for i, c := range runes {
+		switch {
+		case c == '_' || c == '-' || c == '.' || unicode.IsSpace(c):
+			flush()
+			continue
+		case unicode.IsUpper(c) && i > 0 && (unicode.IsLower(runes[i-1]) ||
+			(i+1 < len(runes) && unicode.IsLower(runes[i+1]) && unicode.IsUpper(runes[i-1]))):
+			flush()
+		}
+		word = append(word, c)
+	}
+	flush()
+	return words
+}
+
+// containsWords reports whether sub appears in words as a contiguous run.
+func containsWords(words, sub []string) bool {
+	if len(sub) == 0 {
+		return false
+	}
+	for i := 0; i+len(sub) <= len(words); i++ {
+		match := true
+		for j, w := range sub {
+			if words[i+j] != w {
+				match = false
+				break
+			}
+		}
+		if match {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *redactor) string(s string) string {
+	for _, p := range r.patterns {
+		s = p.ReplaceAllStringFunc(s, func(m string) string {
+			if p == CreditCardPattern && !luhnValid(m) {
+				return m
+			}
+			return r.mask
+		})
+	}
+	return s
+}
+
+// luhnValid reports whether the digits in s pass the Luhn checksum used by card numbers.
+func luhnValid(s string) bool {
+	sum, n := 0, 0
+	for i := len(s) - 1; i >= 0; i-- {
+		c := s[i]
+		if c < '0' || c > '9' {
+			continue
+		}
+		d := int(c - '0')
+		if n%2 == 1 {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		n++
+	}
+	return n > 0 && sum%10 == 0
+}
+
+func isSensitive(v slog.Value) bool {
+	if v.Kind() != slog.KindAny && v.Kind() != slog.KindLogValuer {
+		return false
+	}
+	s, ok := v.Any().(Sensitive)
+	return ok && s.Sensitive()
+}
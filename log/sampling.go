@@ -0,0 +1,50 @@
+package log
+
+import (
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// samplingStats counts sampling decisions across all handlers since start or Reset.
+var samplingStats struct {
+	logged  atomic.Uint64
+	dropped atomic.Uint64
+}
+
+// SamplingStats holds the counts of entries passed and dropped by sampling.
+type SamplingStats struct {
+	Logged  uint64 `json:"logged"`
+	Dropped uint64 `json:"dropped"`
+}
+
+// GetSamplingStats returns the counts of sampled entries since start or Reset.
+// They only grow, so they can be exported as counters and alerted on.
+func GetSamplingStats() SamplingStats {
+	return SamplingStats{
+		Logged:  samplingStats.logged.Load(),
+		Dropped: samplingStats.dropped.Load(),
+	}
+}
+
+func resetSamplingStats() {
+	samplingStats.logged.Store(0)
+	samplingStats.dropped.Store(0)
+}
+
+// newSampler wraps core with zap's sampler, counting its decisions.
+func newSampler(core zapcore.Core, opts *SamplingOptions) zapcore.Core {
+	tick := opts.Tick
+	if tick <= 0 {
+		tick = time.Second
+	}
+	return zapcore.NewSamplerWithOptions(core, tick, opts.First, opts.Thereafter,
+		zapcore.SamplerHook(func(_ zapcore.Entry, dec zapcore.SamplingDecision) {
+			if dec&zapcore.LogDropped != 0 {
+				samplingStats.dropped.Add(1)
+			} else {
+				samplingStats.logged.Add(1)
+			}
+		}))
+}
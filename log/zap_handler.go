@@ -2,6 +2,7 @@ package log
 
 import (
 	"context"
+	"errors"
 	"io"
 	"log/slog"
 	"os"
@@ -15,68 +16,228 @@ import (
 type ZapHandler struct {
 	slog.Handler
 	opts *Options
+
+	core     zapcore.Core
+	level    zap.AtomicLevel
+	module   string
+	redactor *redactor
+
+	// closers are the writers owned by the handler, such as log files.
+	closers []io.Closer
 }
 
+// NewZapHandler creates a handler writing to every sink in opts.Sinks, or to the
+// sink described by Format, Output and FilePath if there are none.
 func NewZapHandler(opts *Options, level zap.AtomicLevel) *ZapHandler {
-	var encoder zapcore.Encoder
+	var cores []zapcore.Core
+	var closers []io.Closer
+	for _, sink := range opts.sinks() {
+		enabler := sinkEnabler{global: moduleEnabler{global: level}, min: sink.Level}
+		if sink.Output == OutputOTLP && sink.Writer == nil {
+			exp := newOTLPExporter(sink, opts.ServiceName)
+			closers = append(closers, exp)
+			cores = append(cores, &otlpCore{LevelEnabler: enabler, exp: exp})
+			continue
+		}
 
-	if opts.Format == FormatJSON {
-		cfg := zap.NewProductionEncoderConfig()
-		cfg.EncodeTime = zapcore.RFC3339TimeEncoder
-		if !opts.EnableStack {
-			cfg.StacktraceKey = ""
+		writer, closer := newWriter(sink, opts)
+		ws := zapcore.AddSync(writer)
+		if opts.Buffer != nil {
+			buffered := newBufferedWriter(ws, opts.Buffer)
+			ws = buffered
+			// Stop flushes the buffer, so it runs before the writer is closed
+			closers = append(closers, closerFunc(buffered.Stop))
 		}
-		encoder = zapcore.NewJSONEncoder(cfg)
-	} else {
-		cfg := zap.NewDevelopmentEncoderConfig()
-		cfg.EncodeTime = zapcore.RFC3339TimeEncoder
-		if !opts.EnableStack {
-			cfg.StacktraceKey = ""
+		if closer != nil {
+			closers = append(closers, closer)
 		}
-		encoder = zapcore.NewConsoleEncoder(cfg)
+		cores = append(cores, zapcore.NewCore(
+			newEncoder(sink.Format, opts),
+			ws,
+			enabler,
+		))
+	}
+	core := zapcore.NewTee(cores...)
+	if opts.Sampling != nil {
+		core = newSampler(core, opts.Sampling)
+	}
+
+	// zapslog options
+	slHandler := zapslog.NewHandler(core, zapslog.WithCaller(opts.EnableCaller))
+
+	h := &ZapHandler{
+		Handler: slHandler,
+		opts:    opts,
+		core:    core,
+		level:   level,
+		closers: closers,
+	}
+	if opts.Redact != nil {
+		h.redactor = newRedactor(opts.Redact)
 	}
+	return h
+}
 
-	var writer io.Writer
-	switch opts.Output {
+func newEncoder(format Format, opts *Options) zapcore.Encoder {
+	cfg := zap.NewDevelopmentEncoderConfig()
+	if format == FormatJSON {
+		cfg = zap.NewProductionEncoderConfig()
+	}
+	cfg.EncodeTime = zapcore.RFC3339TimeEncoder
+	if opts.Encoder != nil {
+		opts.Encoder.apply(&cfg)
+	}
+	if !opts.EnableStack {
+		cfg.StacktraceKey = ""
+	}
+
+	if format == FormatJSON {
+		return zapcore.NewJSONEncoder(cfg)
+	}
+	return zapcore.NewConsoleEncoder(cfg)
+}
+
+// newWriter opens the writer of sink. The closer is set for writers the handler owns.
+func newWriter(sink Sink, opts *Options) (io.Writer, io.Closer) {
+	if sink.Writer != nil {
+		return sink.Writer, nil
+	}
+
+	switch sink.Output {
 	case OutputStdout:
-		writer = os.Stdout
+		return consoleWriter{os.Stdout}, nil
 	case OutputStderr:
-		writer = os.Stderr
+		return consoleWriter{os.Stderr}, nil
 	case OutputFile:
-		writer = &lumberjack.Logger{
-			Filename:   opts.FilePath,
+		file := &lumberjack.Logger{
+			Filename:   sink.FilePath,
 			MaxSize:    opts.MaxSize,
 			MaxBackups: opts.MaxBackups,
 			MaxAge:     opts.MaxAge,
 			Compress:   opts.Compress,
 			LocalTime:  true,
 		}
+		return file, file
 	default:
-		writer = os.Stdout
+		return consoleWriter{os.Stdout}, nil
 	}
+}
 
-	core := zapcore.NewCore(
-		encoder,
-		zapcore.AddSync(writer),
-		level,
-	)
+// consoleWriter hides the Sync method of stdout and stderr, which fails for terminals
+// and pipes and has nothing to flush.
+type consoleWriter struct {
+	io.Writer
+}
 
-	// zapslog options
-	slHandler := zapslog.NewHandler(core, zapslog.WithCaller(opts.EnableCaller))
+// sinkEnabler applies the threshold of a sink on top of the global and module levels.
+type sinkEnabler struct {
+	global zapcore.LevelEnabler
+	min    slog.Leveler
+}
 
-	return &ZapHandler{
-		Handler: slHandler,
-		opts:    opts,
+func (e sinkEnabler) Enabled(l zapcore.Level) bool {
+	if e.min != nil && fromZapLevel(l) < e.min.Level() {
+		return false
+	}
+	return e.global.Enabled(l)
+}
+
+// Sync flushes buffered log entries.
+func (h *ZapHandler) Sync() error {
+	return h.core.Sync()
+}
+
+// Close flushes the handler and closes the writers it owns, such as log files.
+// Standard output and error are never closed. Handlers derived from it keep
+// writing to its sinks without buffering.
+func (h *ZapHandler) Close() error {
+	return h.close(context.Background())
+}
+
+// contextCloser is implemented by closers that can abandon closing when ctx is done.
+type contextCloser interface {
+	closeContext(ctx context.Context) error
+}
+
+// closeContext closes the handler like Close, but returns when ctx is done. Closers
+// that support it are cancelled then; the others finish in the background.
+func (h *ZapHandler) closeContext(ctx context.Context) error {
+	done := make(chan error, 1)
+	go func() {
+		done <- h.close(ctx)
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (h *ZapHandler) close(ctx context.Context) error {
+	// Closing flushes buffers and exporters first, so the final sync has little to do
+	var errs []error
+	for _, c := range h.closers {
+		if cc, ok := c.(contextCloser); ok {
+			errs = append(errs, cc.closeContext(ctx))
+		} else {
+			errs = append(errs, c.Close())
+		}
 	}
+	errs = append(errs, h.Sync())
+	return errors.Join(errs...)
+}
+
+// named returns a handler for module writing to the same core.
+func (h *ZapHandler) named(module string) *ZapHandler {
+	named := *h
+	named.Handler = zapslog.NewHandler(h.core, zapslog.WithCaller(h.opts.EnableCaller), zapslog.WithName(module))
+	named.module = module
+	return &named
+}
+
+// Enabled applies the module level if one is set, else the global level.
+func (h *ZapHandler) Enabled(_ context.Context, l slog.Level) bool {
+	if h.module != "" {
+		if min, ok := moduleLevels.level(h.module); ok {
+			return l >= min
+		}
+	}
+	return h.level.Enabled(toZapLevel(l))
+}
+
+func (h *ZapHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if h.redactor != nil {
+		attrs = h.redactor.attrs(attrs)
+	}
+	c := *h
+	c.Handler = h.Handler.WithAttrs(attrs)
+	return &c
+}
+
+func (h *ZapHandler) WithGroup(name string) slog.Handler {
+	c := *h
+	c.Handler = h.Handler.WithGroup(name)
+	return &c
 }
 
 // Handle overrides the underlying handler's Handle method to inject context fields.
 func (h *ZapHandler) Handle(ctx context.Context, record slog.Record) error {
+	if !h.Enabled(ctx, record.Level) {
+		return nil
+	}
+
 	// Extract fields from context and add them to the record
 	fields := contextFields(ctx)
+	if h.opts.OTelTrace {
+		fields = withOTelTrace(ctx, fields)
+	}
 	if len(fields) > 0 {
 		record.AddAttrs(fields...)
 	}
+	if h.redactor != nil {
+		record = h.redactor.record(record)
+	}
 
 	return h.Handler.Handle(ctx, record)
 }
@@ -93,3 +254,16 @@ func toZapLevel(l slog.Level) zapcore.Level {
 		return zapcore.ErrorLevel
 	}
 }
+
+func fromZapLevel(l zapcore.Level) slog.Level {
+	switch {
+	case l < zapcore.InfoLevel:
+		return slog.LevelDebug
+	case l < zapcore.WarnLevel:
+		return slog.LevelInfo
+	case l < zapcore.ErrorLevel:
+		return slog.LevelWarn
+	default:
+		return slog.LevelError
+	}
+}
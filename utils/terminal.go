@@ -3,6 +3,7 @@ package utils
 import (
 	"encoding/json"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
 
@@ -32,8 +33,13 @@ func ShowJsonDiff(oldJson interface{}, newJson interface{}) (string, error) {
 
 // PrintTable prints a table to stdout.
 func PrintTable(header []interface{}, rows [][]interface{}) {
+	FprintTable(os.Stdout, header, rows)
+}
+
+// FprintTable prints a table to w.
+func FprintTable(w io.Writer, header []interface{}, rows [][]interface{}) {
 	t := table.NewWriter()
-	t.SetOutputMirror(os.Stdout)
+	t.SetOutputMirror(w)
 	t.AppendHeader(header)
 	for _, row := range rows {
 		t.AppendRow(row)
@@ -85,6 +85,13 @@ func TestPrintTable(t *testing.T) {
 	assert.Contains(t, output, "AGE")
 }
 
+func TestFprintTable(t *testing.T) {
+	var buf bytes.Buffer
+	FprintTable(&buf, []interface{}{"Name"}, [][]interface{}{{"Alice"}})
+	assert.Contains(t, buf.String(), "NAME")
+	assert.Contains(t, buf.String(), "Alice")
+}
+
 func TestEditInTempFile(t *testing.T) {
 	// Create a dummy editor script
 	editorContent := `#!/bin/sh